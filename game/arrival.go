@@ -0,0 +1,29 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"math/rand"
+	"time"
+)
+
+// minArrivalInterval keeps the drawn intervals positive
+const minArrivalInterval = time.Millisecond
+
+// arrivalInterval draws the time until the next vehicle from the arrival distribution of the given mean
+func arrivalInterval(arrival api.Arrival, mean time.Duration, rng *rand.Rand) time.Duration {
+	var interval time.Duration
+	switch arrival {
+	case api.ExponentialArrival:
+		interval = time.Duration(rng.ExpFloat64() * float64(mean))
+	case api.UniformArrival:
+		interval = time.Duration(rng.Float64() * 2 * float64(mean))
+	case api.NormalArrival:
+		interval = time.Duration((1 + rng.NormFloat64()/4) * float64(mean))
+	default:
+		return mean
+	}
+	if interval < minArrivalInterval {
+		return minArrivalInterval
+	}
+	return interval
+}
@@ -16,43 +16,208 @@ type city struct {
 
 	api.Runnable
 
-	parentSimulation                   *simulation
-	entryQueue                         *utils.UnboundedChan[vehicle]
-	generationTicker, processingTicker *time.Ticker
-	roadsOut, roadsIn                  []*road
-	roadsMu                            sync.RWMutex
+	parentSimulation *simulation
+	rng              *rand.Rand
+
+	entryQueue   []*vehicle
+	entryQueueMu sync.Mutex
+	// created is the simulated time the city was created, queue counters and stranded are protected by entryQueueMu
+	created        time.Duration
+	maxQueueLength int
+	processed      int
+	stranded       int
+	waitSum        time.Duration
+
+	// simulated time elapsed since the last generation and the last processing
+	generationElapsed, processingElapsed time.Duration
+	// nextArrival is the drawn time between the last generated vehicle and the next one, zero if not drawn yet
+	nextArrival time.Duration
+	// dwelling are the vehicles of the lines stopping in the city, protected by entryQueueMu
+	dwelling []dwellingVehicle
+
+	roadsOut, roadsIn []*road
+	roadsMu           sync.RWMutex
+}
+
+// dwellingVehicle leaves the city at the simulated time until
+type dwellingVehicle struct {
+	v     *vehicle
+	until time.Duration
 }
 
 func newCity(data api.CityData, parentSimulation *simulation) *city {
 	c := &city{
 		CityData:         data,
 		parentSimulation: parentSimulation,
-		entryQueue:       utils.NewUnboundedChan[vehicle](),
-		generationTicker: time.NewTicker(data.GenerationTime),
-		processingTicker: time.NewTicker(data.ProcessingTime),
+		rng:              parentSimulation.randSource(data.Name),
+		entryQueue:       make([]*vehicle, 0),
+		created:          parentSimulation.clock.Now(),
 		roadsIn:          make([]*road, 0),
 		roadsOut:         make([]*road, 0),
 	}
-	c.Runnable = utils.NewBaseRunnable(c)
+	c.SetProfile(data.Profile)
+	c.SetFleet(data.Fleet)
+	c.Runnable = utils.NewFlagRunnable()
 	return c
 }
 
-func (c *city) enqueue(v *vehicle) {
-	c.entryQueue.In() <- v
+// enqueue adds v to the entry queue, ok is false if the city is full
+func (c *city) enqueue(v *vehicle) (ok bool) {
+	capacity := c.Capacity()
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	if capacity > 0 && len(c.entryQueue) >= capacity {
+		return false
+	}
+	v.queueEntered = c.parentSimulation.clock.Now()
+	c.entryQueue = append(c.entryQueue, v)
+	if len(c.entryQueue) > c.maxQueueLength {
+		c.maxQueueLength = len(c.entryQueue)
+	}
+	return true
 }
+
+// restoreQueued appends v to the entry queue regardless of the capacity, a city saved full is restored full
+func (c *city) restoreQueued(v *vehicle) {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	v.queueEntered = c.parentSimulation.clock.Now()
+	c.entryQueue = append(c.entryQueue, v)
+	if len(c.entryQueue) > c.maxQueueLength {
+		c.maxQueueLength = len(c.entryQueue)
+	}
+}
+
+// queued returns the vehicles in the entry queue, in queue order
+func (c *city) queued() []*vehicle {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	vehicles := make([]*vehicle, len(c.entryQueue))
+	copy(vehicles, c.entryQueue)
+	return vehicles
+}
+func (c *city) dequeue(now time.Duration) *vehicle {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	if len(c.entryQueue) == 0 {
+		return nil
+	}
+	v := c.entryQueue[0]
+	c.entryQueue[0] = nil
+	c.entryQueue = c.entryQueue[1:]
+	c.processed++
+	c.waitSum += now - v.queueEntered
+	return v
+}
+
+// route sends v toward the next city of its trip. When the road to it is missing or closed the trip is planned again,
+// a vehicle whose destination cannot be reached anymore is stranded. The vehicles of a line never skip a stop,
+// they are stranded when the road to the next stop is missing or closed
 func (c *city) route(v *vehicle) {
-	if v.trip.Arrived() {
+	next, arrived := v.nextCity()
+	if !arrived && v.Line() == "" && (v.Reroutes() || !c.open(next.Name())) {
+		trip := v.Trip()
+		path := c.parentSimulation.replan(c.Name(), trip.Dst().Name(), v.Type().Class().RouteCost)
+		if len(path) == 0 {
+			c.strand(v)
+			return
+		}
+		v.replan(path)
+		trip = v.Trip()
+		next, arrived = trip.Current(), trip.Arrived()
+	}
+	if arrived {
+		c.parentSimulation.stats.recordTrip(v, c.parentSimulation.clock.Now())
+		c.parentSimulation.emit(api.TripCompleted, v, c, nil)
 		return
 	}
-	v.trip.Next()
+	if r := c.roadTo(next.Name()); r != nil && !r.Closed() {
+		r.route(v)
+	} else {
+		c.strand(v)
+	}
+}
+
+// dwell keeps v in the city for the dwell time of its line when the city is an intermediate stop, it reports whether v stays
+func (c *city) dwell(v *vehicle, at time.Duration) bool {
+	if v.Line() == "" {
+		return false
+	}
+	l := c.parentSimulation.line(v.Line())
+	if l == nil {
+		return false
+	}
+	dwell := l.Dwell()
+	trip := v.Trip()
+	if data := trip.Data(); dwell <= 0 || data.Index >= len(data.Cities)-1 {
+		return false
+	}
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	c.dwelling = append(c.dwelling, dwellingVehicle{v: v, until: at + dwell})
+	return true
+}
+
+// restoreDwelling keeps v in the city until the simulated time until
+func (c *city) restoreDwelling(v *vehicle, until time.Duration) {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	c.dwelling = append(c.dwelling, dwellingVehicle{v: v, until: until})
+}
+
+// dwellingVehicles returns the vehicles dwelling in the city
+func (c *city) dwellingVehicles() []dwellingVehicle {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	dwelling := make([]dwellingVehicle, len(c.dwelling))
+	copy(dwelling, c.dwelling)
+	return dwelling
+}
+
+// departing removes the vehicles whose dwell time is over at the simulated time at
+func (c *city) departing(at time.Duration) []*vehicle {
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	vehicles := make([]*vehicle, 0)
+	dwelling := c.dwelling[:0]
+	for _, d := range c.dwelling {
+		if d.until <= at {
+			vehicles = append(vehicles, d.v)
+		} else {
+			dwelling = append(dwelling, d)
+		}
+	}
+	for i := len(dwelling); i < len(c.dwelling); i++ {
+		c.dwelling[i] = dwellingVehicle{}
+	}
+	c.dwelling = dwelling
+	return vehicles
+}
+
+// strand drops v from the simulation
+func (c *city) strand(v *vehicle) {
+	c.entryQueueMu.Lock()
+	c.stranded++
+	c.entryQueueMu.Unlock()
+	c.parentSimulation.emit(api.VehicleStranded, v, c, nil)
+}
+
+// open reports whether the road from c to the named city exists and is not closed
+func (c *city) open(name string) bool {
+	r := c.roadTo(name)
+	return r != nil && !r.Closed()
+}
+
+// roadTo returns the road from c to the named city, nil if there is none
+func (c *city) roadTo(name string) *road {
 	c.roadsMu.RLock()
 	defer c.roadsMu.RUnlock()
 	for _, r := range c.roadsOut {
-		if r.Dst().Name() == v.trip.Current().Name() {
-			r.route(v)
-			return
+		if r.Dst().Name() == name {
+			return r
 		}
 	}
+	return nil
 }
 
 func (c *city) addRoadIn(r *road) {
@@ -95,26 +260,106 @@ func (c *city) remRoadOut(r *road) {
 		}
 	}
 }
+
+// generateVehicle returns nil when no city can be reached from c
 func (c *city) generateVehicle() *vehicle {
-	pSpeed := float64(80 + rand.Intn(500))
+	vt := c.pickVehicleType()
+	class := vt.Class()
+	pSpeed := float64(class.MinSpeed)
+	if class.MaxSpeed > class.MinSpeed {
+		pSpeed += float64(c.rng.Intn(class.MaxSpeed - class.MinSpeed + 1))
+	}
+	trip, ok := c.parentSimulation.generateTrip(c.Name(), c.rng, class.RouteCost)
+	if !ok {
+		return nil
+	}
+	// the random source is only drawn when rerouting is enabled, so runs without it keep their sequence
+	compliance := c.parentSimulation.Compliance()
+	reroutes := compliance > 0 && c.rng.Float64() < compliance
+	col := class.Color
+	if col.A == 0 {
+		col = colorToRgba(c.Color())
+	}
 	v := newVehicle(api.VehicleData{
 		Plate:          c.parentSimulation.generatePlate(),
-		Color:          colorToRgba(c.Color()),
+		Type:           vt,
+		Color:          col,
 		PreferredSpeed: pSpeed,
-	}, c.parentSimulation.generateTrip(c.Name(), pSpeed))
+		Reroutes:       reroutes,
+		Departure:      c.parentSimulation.clock.Now(),
+	}, trip)
 	return v
 }
 
-func (c *city) Update(uint64) {
-	select {
-	case <-c.generationTicker.C:
-		v := c.generateVehicle()
+// pickVehicleType draws the type of a new vehicle from the fleet mix, the random source
+// is only drawn for a mixed fleet so that cities generating only cars keep their sequence
+func (c *city) pickVehicleType() api.VehicleType {
+	fleet := c.Fleet()
+	total := 0.0
+	for _, vt := range api.VehicleTypes {
+		total += fleet[vt]
+	}
+	if total <= 0 || total == fleet[api.Car] {
+		return api.Car
+	}
+	x := c.rng.Float64() * total
+	picked := api.Car
+	for _, vt := range api.VehicleTypes {
+		if fleet[vt] <= 0 {
+			continue
+		}
+		picked = vt
+		if x < fleet[vt] {
+			break
+		}
+		x -= fleet[vt]
+	}
+	return picked
+}
+
+// Update generates vehicles with a mean interval of GenerationTime, scaled by the profile of the hour, and polls the queue every ProcessingTime of simulated time
+func (c *city) Update(now, elapsed time.Duration) {
+	generationTime, processingTime := c.GenerationTime(), c.ProcessingTime()
+
+	c.processingElapsed += elapsed
+	for processingTime > 0 && c.processingElapsed >= processingTime {
+		c.processingElapsed -= processingTime
+		at := now + elapsed - c.processingElapsed
+		if v := c.dequeue(at); v != nil && !c.dwell(v, at) {
+			c.route(v)
+		}
+	}
+	for _, v := range c.departing(now + elapsed) {
 		c.route(v)
-	case <-c.processingTicker.C:
-		select {
-		case v := <-c.entryQueue.Out():
+	}
+
+	if profile := c.Profile(); len(profile) == 0 {
+		c.generationElapsed += elapsed
+	} else {
+		// the rate multiplier stretches the elapsed time, a tick is split at the hours it crosses
+		for t, end := now, now+elapsed; t < end; {
+			next := (t/time.Hour + 1) * time.Hour
+			if next > end {
+				next = end
+			}
+			c.generationElapsed += time.Duration(float64(next-t) * api.ProfileFactor(profile, t))
+			t = next
+		}
+	}
+	arrival := c.Arrival()
+	for generationTime > 0 {
+		// a fixed interval follows the changes of GenerationTime
+		if c.nextArrival == 0 || arrival == api.FixedArrival {
+			c.nextArrival = arrivalInterval(arrival, generationTime, c.rng)
+		}
+		if c.generationElapsed < c.nextArrival {
+			break
+		}
+		c.generationElapsed -= c.nextArrival
+		c.nextArrival = 0
+		if v := c.generateVehicle(); v != nil {
+			c.parentSimulation.emit(api.VehicleSpawned, v, c, nil)
 			c.route(v)
-		default:
 		}
 	}
 }
@@ -149,7 +394,51 @@ func (c *city) SetGenerationTime(duration time.Duration) {
 	c.propertyMu.Lock()
 	defer c.propertyMu.Unlock()
 	c.CityData.GenerationTime = duration
-	c.generationTicker.Reset(duration)
+}
+func (c *city) Arrival() api.Arrival {
+	c.propertyMu.RLock()
+	defer c.propertyMu.RUnlock()
+	return c.CityData.Arrival
+}
+func (c *city) SetArrival(arrival api.Arrival) {
+	c.propertyMu.Lock()
+	defer c.propertyMu.Unlock()
+	c.CityData.Arrival = arrival
+}
+func (c *city) Fleet() map[api.VehicleType]float64 {
+	c.propertyMu.RLock()
+	defer c.propertyMu.RUnlock()
+	return copyFleet(c.CityData.Fleet)
+}
+func (c *city) SetFleet(fleet map[api.VehicleType]float64) {
+	fleet = copyFleet(fleet)
+	c.propertyMu.Lock()
+	defer c.propertyMu.Unlock()
+	c.CityData.Fleet = fleet
+}
+func (c *city) Profile() []float64 {
+	c.propertyMu.RLock()
+	defer c.propertyMu.RUnlock()
+	if len(c.CityData.Profile) == 0 {
+		return nil
+	}
+	profile := make([]float64, len(c.CityData.Profile))
+	copy(profile, c.CityData.Profile)
+	return profile
+}
+func (c *city) SetProfile(profile []float64) bool {
+	if !api.ValidProfile(profile) {
+		return false
+	}
+	var profileCopy []float64
+	if len(profile) > 0 {
+		profileCopy = make([]float64, len(profile))
+		copy(profileCopy, profile)
+	}
+	c.propertyMu.Lock()
+	defer c.propertyMu.Unlock()
+	c.CityData.Profile = profileCopy
+	return true
 }
 func (c *city) ProcessingTime() time.Duration {
 	c.propertyMu.RLock()
@@ -160,7 +449,16 @@ func (c *city) SetProcessingTime(duration time.Duration) {
 	c.propertyMu.Lock()
 	defer c.propertyMu.Unlock()
 	c.CityData.ProcessingTime = duration
-	c.processingTicker.Reset(duration)
+}
+func (c *city) Capacity() int {
+	c.propertyMu.RLock()
+	defer c.propertyMu.RUnlock()
+	return c.CityData.Capacity
+}
+func (c *city) SetCapacity(capacity int) {
+	c.propertyMu.Lock()
+	defer c.propertyMu.Unlock()
+	c.CityData.Capacity = capacity
 }
 func (c *city) RoadsIn() []api.Road {
 	c.roadsMu.RLock()
@@ -176,11 +474,31 @@ func (c *city) RoadsOut() []api.Road {
 	defer c.roadsMu.RUnlock()
 	rs := make([]api.Road, len(c.roadsOut))
 	for i := 0; i < len(c.roadsOut); i++ {
-		rs[i] = c.roadsIn[i]
+		rs[i] = c.roadsOut[i]
 	}
 	return rs
 }
 
+func (c *city) Metrics() api.CityMetrics {
+	now := c.parentSimulation.clock.Now()
+	c.entryQueueMu.Lock()
+	defer c.entryQueueMu.Unlock()
+	m := api.CityMetrics{
+		QueueLength:    len(c.entryQueue),
+		MaxQueueLength: c.maxQueueLength,
+		Processed:      c.processed,
+		Stranded:       c.stranded,
+		Dwelling:       len(c.dwelling),
+	}
+	if c.processed > 0 {
+		m.MeanWaitTime = c.waitSum / time.Duration(c.processed)
+	}
+	if alive := now - c.created; alive > 0 {
+		m.ProcessedPerHour = float64(c.processed) / alive.Hours()
+	}
+	return m
+}
+
 func (c *city) Links() []graph.Link {
 	links := make([]graph.Link, len(c.roadsOut))
 	for i := range links {
@@ -188,3 +506,15 @@ func (c *city) Links() []graph.Link {
 	}
 	return links
 }
+
+// copyFleet returns a copy of fleet, nil when it is empty
+func copyFleet(fleet map[api.VehicleType]float64) map[api.VehicleType]float64 {
+	if len(fleet) == 0 {
+		return nil
+	}
+	fleetCopy := make(map[api.VehicleType]float64, len(fleet))
+	for vt, share := range fleet {
+		fleetCopy[vt] = share
+	}
+	return fleetCopy
+}
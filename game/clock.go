@@ -0,0 +1,88 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"sync"
+	"time"
+)
+
+// frameTime is the wall time between two ticks of a running simulation
+const frameTime = time.Second / 60
+
+type clock struct {
+	mode api.ClockMode
+	step time.Duration
+
+	now   time.Duration
+	nowMu sync.RWMutex
+
+	ticker   *time.Ticker
+	lastWall time.Time
+	budget   time.Duration
+}
+
+func newClock(data api.ClockData) *clock {
+	step := data.Step
+	if step <= 0 {
+		step = api.DefaultStep
+	}
+	return &clock{mode: data.Mode, step: step, now: data.Now}
+}
+
+// start resets the wall reference, it is called when the simulation starts running
+func (c *clock) start() {
+	if c.ticker == nil {
+		c.ticker = time.NewTicker(frameTime)
+	} else {
+		c.ticker.Reset(frameTime)
+	}
+	c.lastWall = time.Now()
+	c.budget = 0
+}
+func (c *clock) stop() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+	}
+}
+
+// wait blocks until the next frame and returns the simulated durations of the ticks to run.
+// RealTime mode returns a single tick of the scaled elapsed wall time,
+// FixedStep mode returns as many whole steps as the scaled elapsed wall time covers
+func (c *clock) wait(speed float64) []time.Duration {
+	<-c.ticker.C
+	wall := time.Now()
+	elapsed := time.Duration(float64(wall.Sub(c.lastWall)) * speed)
+	c.lastWall = wall
+	if c.mode != api.FixedStep {
+		return []time.Duration{elapsed}
+	}
+	c.budget += elapsed
+	ticks := make([]time.Duration, 0, c.budget/c.step)
+	for c.budget >= c.step {
+		ticks = append(ticks, c.step)
+		c.budget -= c.step
+	}
+	return ticks
+}
+
+func (c *clock) advance(dt time.Duration) {
+	c.nowMu.Lock()
+	defer c.nowMu.Unlock()
+	c.now += dt
+}
+
+func (c *clock) packData() api.ClockData {
+	return api.ClockData{Mode: c.mode, Step: c.step, Now: c.Now()}
+}
+
+func (c *clock) Now() time.Duration {
+	c.nowMu.RLock()
+	defer c.nowMu.RUnlock()
+	return c.now
+}
+func (c *clock) Mode() api.ClockMode {
+	return c.mode
+}
+func (c *clock) Step() time.Duration {
+	return c.step
+}
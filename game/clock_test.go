@@ -0,0 +1,96 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"testing"
+	"time"
+)
+
+func TestNewClock(t *testing.T) {
+	c := newClock(api.ClockData{Mode: api.FixedStep, Now: time.Hour})
+	if c.Step() != api.DefaultStep || c.Now() != time.Hour {
+		t.Fatalf("clock has step %v at %v", c.Step(), c.Now())
+	}
+	c.advance(time.Minute)
+	if data := c.packData(); data != (api.ClockData{Mode: api.FixedStep, Step: api.DefaultStep, Now: time.Hour + time.Minute}) {
+		t.Fatalf("clock packed as %+v", data)
+	}
+}
+
+func TestClockWaitFixedStep(t *testing.T) {
+	c := newClock(api.ClockData{Mode: api.FixedStep, Step: time.Second})
+	c.start()
+	defer c.stop()
+	// 2.5s of wall time at speed 1, plus the frame waited
+	c.lastWall = time.Now().Add(-2500 * time.Millisecond)
+	if ticks := c.wait(1); len(ticks) != 2 || ticks[0] != time.Second || ticks[1] != time.Second {
+		t.Fatalf("fixed clock returned the ticks %v", ticks)
+	}
+	// the half step left over is carried to the next frame
+	c.lastWall = time.Now().Add(-600 * time.Millisecond)
+	if ticks := c.wait(1); len(ticks) != 1 {
+		t.Fatalf("fixed clock returned the ticks %v with the carried budget", ticks)
+	}
+	if c.budget >= c.step {
+		t.Fatalf("budget %v is longer than a step", c.budget)
+	}
+}
+
+func TestClockWaitRealTime(t *testing.T) {
+	c := newClock(api.ClockData{Mode: api.RealTime, Step: time.Second})
+	c.start()
+	defer c.stop()
+	c.lastWall = time.Now().Add(-100 * time.Millisecond)
+	ticks := c.wait(60)
+	if len(ticks) != 1 || ticks[0] < 6*time.Second || ticks[0] > 60*time.Second {
+		t.Fatalf("real time clock returned the ticks %v at speed 60", ticks)
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	data := testSimulationData()
+	data.Speed = 60
+	sim := NewFromData(data)
+	spawned := 0
+	sim.Subscribe(func(e api.Event) {
+		spawned++
+	}, api.VehicleSpawned)
+	sim.Advance(2*time.Minute + 500*time.Millisecond)
+	if now := sim.Clock().Now(); now != 2*time.Minute+500*time.Millisecond {
+		t.Fatalf("clock is at %v", now)
+	}
+	if spawned == 0 {
+		t.Fatal("no vehicle spawned while advancing")
+	}
+
+	// Stop must end the loop before stopping the clock it waits on
+	stop := func() {
+		stopped := make(chan struct{})
+		go func() {
+			sim.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stop did not return, the loop is blocked on the stopped clock")
+		}
+	}
+	now := sim.Clock().Now()
+	sim.Start()
+	sim.Advance(time.Hour)
+	for i := 0; i < 5; i++ {
+		time.Sleep(30 * time.Millisecond)
+		stop()
+		sim.Start()
+	}
+	stop()
+	if elapsed := sim.Clock().Now() - now; elapsed <= 0 || elapsed >= time.Hour {
+		t.Fatalf("clock ran %v while the simulation was running", elapsed)
+	}
+	stoppedAt := sim.Clock().Now()
+	time.Sleep(50 * time.Millisecond)
+	if sim.Clock().Now() != stoppedAt {
+		t.Fatal("clock advanced after Stop")
+	}
+}
@@ -0,0 +1,59 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"sync"
+)
+
+type subscriber struct {
+	id    uint64
+	fn    api.EventCallback
+	types map[api.EventType]bool
+}
+
+type eventBus struct {
+	subscribers   []subscriber
+	nextId        uint64
+	subscribersMu sync.RWMutex
+}
+
+func (b *eventBus) subscribe(fn api.EventCallback, types ...api.EventType) func() {
+	b.subscribersMu.Lock()
+	defer b.subscribersMu.Unlock()
+	sub := subscriber{id: b.nextId, fn: fn}
+	if len(types) > 0 {
+		sub.types = make(map[api.EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	b.nextId++
+	b.subscribers = append(b.subscribers, sub)
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { b.unsubscribe(sub.id) })
+	}
+}
+func (b *eventBus) unsubscribe(id uint64) {
+	b.subscribersMu.Lock()
+	defer b.subscribersMu.Unlock()
+	for i, sub := range b.subscribers {
+		if sub.id == id {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// emit calls the subscribers in subscription order, it must not be called holding simulation locks
+func (b *eventBus) emit(e api.Event) {
+	b.subscribersMu.RLock()
+	subscribers := b.subscribers
+	b.subscribersMu.RUnlock()
+	for _, sub := range subscribers {
+		if sub.types == nil || sub.types[e.Type] {
+			sub.fn(e)
+		}
+	}
+}
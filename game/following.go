@@ -0,0 +1,178 @@
+package game
+
+import (
+	"math"
+	"time"
+)
+
+// Intelligent Driver Model parameters, lengths are in km and times in hours like the rest of the simulation
+const (
+	// idmAcceleration is the maximum acceleration, 1 m/s²
+	idmAcceleration = 12960
+	// idmDeceleration is the comfortable deceleration, 1.5 m/s²
+	idmDeceleration = 19440
+	// idmMinGap is the distance kept from the leader when standing, 2 m
+	idmMinGap = 0.002
+	// idmHeadway is the time gap kept from the leader, 1.5 s
+	idmHeadway = 1.5 / 3600
+	// idmStep is the longest interval integrated at once, longer updates are split
+	idmStep = time.Second
+)
+
+// idmAccelerationOf returns the acceleration of a vehicle driving at speed toward the desired one,
+// with a leader driving at leaderSpeed gap km ahead. Without a leader gap is +Inf
+func idmAccelerationOf(speed, desired, gap, leaderSpeed float64) float64 {
+	if desired <= 0 {
+		return -idmDeceleration
+	}
+	free := 1 - math.Pow(speed/desired, 4)
+	if math.IsInf(gap, 1) {
+		return idmAcceleration * free
+	}
+	if gap <= 0 {
+		return math.Inf(-1)
+	}
+	desiredGap := idmMinGap + speed*idmHeadway + speed*(speed-leaderSpeed)/(2*math.Sqrt(idmAcceleration*idmDeceleration))
+	desiredGap = math.Max(desiredGap, idmMinGap)
+	return idmAcceleration * (free - (desiredGap/gap)*(desiredGap/gap))
+}
+
+// Lane change parameters, a simplified MOBIL with a bias to keep right
+const (
+	// laneChangeThreshold is the acceleration gained needed to change lane, 0.2 m/s²
+	laneChangeThreshold = 2592
+	// laneKeepRightBias makes changing to the left harder and changing back to the right easier, 0.3 m/s²
+	laneKeepRightBias = 3888
+)
+
+// follower is the state of a vehicle while its road moves it, length is the length of its class
+type follower struct {
+	v                           *vehicle
+	pos, speed, desired, length float64
+	lane                        int
+}
+
+func newFollower(v *vehicle, distance, maxSpeed float64, lanes int) follower {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	f := follower{
+		v:       v,
+		pos:     v.VehicleData.Progress * distance,
+		speed:   v.VehicleData.CurrentSpeed,
+		desired: math.Min(v.VehicleData.PreferredSpeed, maxSpeed),
+		length:  v.VehicleData.Type.Class().Length,
+		lane:    v.VehicleData.Lane,
+	}
+	if f.lane >= lanes {
+		f.lane = lanes - 1
+	}
+	return f
+}
+
+// store writes the state back to the vehicle
+func (f follower) store(distance float64) {
+	progress := 1.0
+	if distance > 0 {
+		progress = math.Min(f.pos/distance, 1)
+	}
+	f.v.propertyMu.Lock()
+	defer f.v.propertyMu.Unlock()
+	f.v.VehicleData.Progress, f.v.VehicleData.CurrentSpeed, f.v.VehicleData.Lane = progress, f.speed, f.lane
+}
+
+// accelerationBehind returns the acceleration of f following leader, a nil leader is no leader
+func (f follower) accelerationBehind(leader *follower) float64 {
+	if leader == nil {
+		return idmAccelerationOf(f.speed, f.desired, math.Inf(1), 0)
+	}
+	return idmAccelerationOf(f.speed, f.desired, leader.pos-f.pos-leader.length, leader.speed)
+}
+
+// changeLanes moves the followers, sorted leader first, to an adjacent lane when it is safe and they gain enough
+// acceleration. Decisions are taken leader first, so every follower sees the lanes already chosen by its leaders
+func changeLanes(followers []follower, distance float64, lanes int) {
+	if lanes < 2 {
+		return
+	}
+	// behind[i*lanes+l] is the index of the nearest follower behind i in lane l, -1 if there is none
+	behind := make([]int, len(followers)*lanes)
+	next := make([]int, lanes)
+	for l := range next {
+		next[l] = -1
+	}
+	for i := len(followers) - 1; i >= 0; i-- {
+		copy(behind[i*lanes:], next)
+		next[followers[i].lane] = i
+	}
+	// ahead[l] is the nearest follower already decided in lane l
+	ahead := make([]*follower, lanes)
+	for i := range followers {
+		f := &followers[i]
+		if f.pos < distance {
+			f.lane = f.bestLane(followers, ahead, behind[i*lanes:(i+1)*lanes])
+		}
+		ahead[f.lane] = f
+	}
+}
+
+func (f *follower) bestLane(followers []follower, ahead []*follower, behind []int) int {
+	current := f.accelerationBehind(ahead[f.lane])
+	best, bestGain := f.lane, 0.0
+	for _, target := range [2]int{f.lane - 1, f.lane + 1} {
+		if target < 0 || target >= len(ahead) {
+			continue
+		}
+		leader := ahead[target]
+		if leader != nil && leader.pos-f.pos-leader.length < idmMinGap {
+			continue
+		}
+		if b := behind[target]; b >= 0 {
+			newFollower := followers[b]
+			if f.pos-newFollower.pos-f.length < idmMinGap || newFollower.accelerationBehind(f) < -idmDeceleration {
+				continue
+			}
+		}
+		gain := f.accelerationBehind(leader) - current - laneChangeThreshold
+		if target > f.lane {
+			gain -= laneKeepRightBias
+		} else {
+			gain += laneKeepRightBias
+		}
+		if gain > bestGain {
+			best, bestGain = target, gain
+		}
+	}
+	return best
+}
+
+// followLeaders moves the followers, sorted leader first, by dt hours using the Intelligent Driver Model.
+// A follower never gets closer than idmMinGap to the leader in its lane. The vehicles past the road end are
+// passed to exit in order, the ones exit refuses wait at the end of the road. The followers still on the road are returned
+func followLeaders(followers []follower, dt, distance float64, lanes int, exit func(*vehicle) bool) []follower {
+	leaderPos, leaderSpeed, leaderLength := make([]float64, lanes), make([]float64, lanes), make([]float64, lanes)
+	for l := range leaderPos {
+		leaderPos[l] = math.Inf(1)
+	}
+	remaining := followers[:0]
+	for _, f := range followers {
+		l := f.lane
+		acc := idmAccelerationOf(f.speed, f.desired, leaderPos[l]-f.pos-leaderLength[l], leaderSpeed[l])
+
+		newSpeed := math.Max(0, f.speed+acc*dt)
+		newPos := f.pos + (f.speed+newSpeed)/2*dt
+		if limit := leaderPos[l] - leaderLength[l] - idmMinGap; newPos > limit {
+			newPos, newSpeed = math.Max(f.pos, limit), math.Min(newSpeed, leaderSpeed[l])
+		}
+		if newPos >= distance {
+			if exit(f.v) {
+				continue
+			}
+			newPos, newSpeed = distance, 0
+		}
+
+		f.pos, f.speed = newPos, newSpeed
+		leaderPos[l], leaderSpeed[l], leaderLength[l] = newPos, newSpeed, f.length
+		remaining = append(remaining, f)
+	}
+	return remaining
+}
@@ -0,0 +1,171 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"image/color"
+	"sort"
+	"sync"
+	"time"
+)
+
+type line struct {
+	api.LineData
+	propertyMu sync.RWMutex
+
+	sim *simulation
+
+	// next is the simulated time of the next departure, it is computed again when scheduled is false
+	next      time.Duration
+	scheduled bool
+}
+
+func newLine(data api.LineData, sim *simulation) *line {
+	data.Stops = append([]string(nil), data.Stops...)
+	l := &line{LineData: data, sim: sim}
+	l.SetDepartures(data.Departures)
+	return l
+}
+
+// Update departs the vehicles scheduled before now+elapsed from the first stop
+func (l *line) Update(now, elapsed time.Duration) {
+	for {
+		next, ok := l.NextDeparture()
+		if !ok || next >= now+elapsed {
+			return
+		}
+		l.propertyMu.Lock()
+		l.next, l.scheduled = l.LineData.NextDeparture(next + 1)
+		l.propertyMu.Unlock()
+		l.depart()
+	}
+}
+
+// depart generates a vehicle travelling through every stop, nothing departs when a stop is missing
+func (l *line) depart() {
+	stops := l.Stops()
+	if len(stops) < 2 || len(stops) != len(l.LineData.Stops) {
+		return
+	}
+	first := stops[0].(*city)
+	vt := l.Type()
+	class := vt.Class()
+	col := colorToRgba(l.Color())
+	if col.A == 0 {
+		col = class.Color
+	}
+	if col.A == 0 {
+		col = colorToRgba(first.Color())
+	}
+	v := newVehicle(api.VehicleData{
+		Plate:          l.sim.generatePlate(),
+		Type:           vt,
+		Color:          col,
+		PreferredSpeed: float64(class.MaxSpeed),
+		Line:           l.Name(),
+		Departure:      l.sim.clock.Now(),
+	}, api.NewTrip(stops))
+	l.sim.emit(api.VehicleSpawned, v, first, nil)
+	first.route(v)
+}
+
+func (l *line) Name() string {
+	return l.LineData.Name
+}
+func (l *line) Type() api.VehicleType {
+	return l.LineData.Type
+}
+func (l *line) Color() color.Color {
+	l.propertyMu.RLock()
+	defer l.propertyMu.RUnlock()
+	return l.LineData.Color
+}
+func (l *line) SetColor(col color.Color) {
+	l.propertyMu.Lock()
+	defer l.propertyMu.Unlock()
+	l.LineData.Color = colorToRgba(col)
+}
+func (l *line) Stops() []api.City {
+	l.sim.citiesMu.RLock()
+	defer l.sim.citiesMu.RUnlock()
+	stops := make([]api.City, 0, len(l.LineData.Stops))
+	for _, name := range l.LineData.Stops {
+		if i, exist := l.sim.cityMap[name]; exist {
+			stops = append(stops, l.sim.cities[i])
+		}
+	}
+	return stops
+}
+func (l *line) Headway() time.Duration {
+	l.propertyMu.RLock()
+	defer l.propertyMu.RUnlock()
+	return l.LineData.Headway
+}
+func (l *line) SetHeadway(headway time.Duration) {
+	if headway < 0 {
+		headway = 0
+	}
+	l.propertyMu.Lock()
+	defer l.propertyMu.Unlock()
+	l.LineData.Headway, l.scheduled = headway, false
+}
+func (l *line) Departures() []time.Duration {
+	l.propertyMu.RLock()
+	defer l.propertyMu.RUnlock()
+	return append([]time.Duration(nil), l.LineData.Departures...)
+}
+func (l *line) SetDepartures(departures []time.Duration) {
+	sorted := make([]time.Duration, 0, len(departures))
+	for _, d := range departures {
+		if d >= 0 && d < api.Day {
+			sorted = append(sorted, d)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 {
+		sorted = nil
+	}
+	l.propertyMu.Lock()
+	defer l.propertyMu.Unlock()
+	l.LineData.Departures, l.scheduled = sorted, false
+}
+func (l *line) Dwell() time.Duration {
+	l.propertyMu.RLock()
+	defer l.propertyMu.RUnlock()
+	return l.LineData.Dwell
+}
+func (l *line) SetDwell(dwell time.Duration) {
+	if dwell < 0 {
+		dwell = 0
+	}
+	l.propertyMu.Lock()
+	defer l.propertyMu.Unlock()
+	l.LineData.Dwell = dwell
+}
+func (l *line) NextDeparture() (next time.Duration, ok bool) {
+	l.propertyMu.Lock()
+	defer l.propertyMu.Unlock()
+	if !l.scheduled {
+		l.next, l.scheduled = l.LineData.NextDeparture(l.sim.clock.Now())
+	}
+	return l.next, l.scheduled
+}
+
+// stopsIn reports whether the line stops in the named city
+func (l *line) stopsIn(name string) bool {
+	for _, stop := range l.LineData.Stops {
+		if stop == name {
+			return true
+		}
+	}
+	return false
+}
+
+// packData returns a copy of the line data
+func (l *line) packData() api.LineData {
+	l.propertyMu.RLock()
+	defer l.propertyMu.RUnlock()
+	data := l.LineData
+	data.Stops = append([]string(nil), data.Stops...)
+	data.Departures = append([]time.Duration(nil), data.Departures...)
+	return data
+}
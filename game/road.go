@@ -4,6 +4,7 @@ import (
 	"github.com/bisoncorp/autostrade/game/utils"
 	api "github.com/bisoncorp/autostrade/gameapi"
 	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -14,100 +15,223 @@ type road struct {
 
 	api.Runnable
 
-	sim    *simulation
-	ticker *time.Ticker
-
-	vehicleQueue *utils.UnboundedChan[vehicle]
+	sim *simulation
 
 	vehicles   []*vehicle
 	vehiclesMu sync.RWMutex
 
-	src, dst     *city
-	lastCallTime time.Time
+	src, dst *city
+
+	metrics   api.RoadMetrics
+	dwellSum  time.Duration
+	history   *utils.Ring[api.RoadSample]
+	metricsMu sync.RWMutex
+	// nextSample is the simulated time of the next history sample
+	nextSample time.Duration
 }
 
+const (
+	// roadSampleInterval is the simulated time between two samples of the road history
+	roadSampleInterval = time.Minute
+	// roadHistorySize keeps a day of samples
+	roadHistorySize = 24 * 60
+	// minFlowSpeedRatio is the lowest fraction of MaxSpeed allowed by the density
+	minFlowSpeedRatio = 0.1
+	// costScale turns the cost of a road into an integer weight, a millionth of hour or of km
+	costScale = 1e6
+)
+
 func newRoad(data api.RoadData, sim *simulation, src, dst *city) *road {
+	if data.Lanes < 1 {
+		data.Lanes = 1
+	}
 	r := &road{
-		RoadData:     data,
-		sim:          sim,
-		ticker:       time.NewTicker(time.Second / 60),
-		vehicleQueue: utils.NewUnboundedChan[vehicle](),
-		vehicles:     make([]*vehicle, 0, 1<<5),
-		dst:          dst,
-		src:          src,
-	}
-	r.Runnable = utils.NewBaseRunnable(r)
+		RoadData: data,
+		sim:      sim,
+		vehicles: make([]*vehicle, 0, 1<<5),
+		dst:      dst,
+		src:      src,
+		history:  utils.NewRing[api.RoadSample](roadHistorySize),
+	}
+	r.nextSample = sim.clock.Now() + roadSampleInterval
+	r.Runnable = utils.NewFlagRunnable()
 	return r
 }
 
-func (r *road) Update(iteration uint64) {
-	select {
-	case <-r.ticker.C:
-		if iteration == 0 {
-			r.lastCallTime = time.Now()
-		}
-		timeElapsed := time.Now().Sub(r.lastCallTime)
-		r.moveVehicles(timeElapsed.Hours() * r.sim.Speed())
-		r.lastCallTime = time.Now()
-	case v := <-r.vehicleQueue.Out():
-		r.vehiclesMu.Lock()
-		r.vehicles = append(r.vehicles, v)
-		r.vehiclesMu.Unlock()
+// Update moves the vehicles on the road by elapsed of simulated time
+func (r *road) Update(now, elapsed time.Duration) {
+	r.moveVehicles(now, elapsed)
+	// a step longer than roadSampleInterval records the same metrics for every interval it crosses
+	for end := now + elapsed; r.nextSample <= end; r.nextSample += roadSampleInterval {
+		r.sample(r.nextSample)
 	}
 }
 
-func (r *road) moveVehicles(timeElapsed float64) {
-	maxSpeed := r.MaxSpeed()
+func (r *road) sample(at time.Duration) {
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+	r.history.Push(api.RoadSample{Time: at, RoadMetrics: r.metrics})
+}
+
+func (r *road) moveVehicles(now time.Duration, elapsed time.Duration) {
+	lanes := r.Lanes()
 	distance := api.Distance(r.src.Position(), r.dst.Position())
 
-	r.vehiclesMu.RLock()
-	vehicles := make([]*vehicle, len(r.vehicles))
-	copy(vehicles, r.vehicles)
-	r.vehiclesMu.RUnlock()
+	r.vehiclesMu.Lock()
+	// leader first, equal progress keeps the order of entry
+	sort.SliceStable(r.vehicles, func(i, j int) bool {
+		return r.vehicles[i].Progress() > r.vehicles[j].Progress()
+	})
+	density := math.Inf(1)
+	if distance > 0 {
+		density = float64(len(r.vehicles)) / distance / float64(lanes)
+	}
+	speed := r.flowSpeed(now, density)
+	followers := make([]follower, len(r.vehicles))
+	for i, v := range r.vehicles {
+		followers[i] = newFollower(v, distance, speed, lanes)
+	}
+	r.vehiclesMu.Unlock()
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(vehicles))
-	for i, v := range vehicles {
-		go func(v *vehicle, i int) {
-			sr := moveVehicle(v, timeElapsed, maxSpeed, distance)
-			if sr {
-				r.dst.enqueue(v)
-				vehicles[i] = nil
-			}
-			wg.Done()
-		}(v, i)
-	}
-	wg.Wait()
-
-	nv := make([]*vehicle, 0, len(vehicles))
-	for _, v := range vehicles {
-		if v != nil {
-			nv = append(nv, v)
+	exited, dwellSum := 0, time.Duration(0)
+	exit := func(v *vehicle) bool {
+		if !r.dst.enqueue(v) {
+			return false
+		}
+		exited++
+		dwellSum += now - v.roadEntered
+		r.sim.emit(api.CityArrived, v, r.dst, r)
+		return true
+	}
+	for elapsed > 0 && len(followers) > 0 {
+		dt := idmStep
+		if elapsed < dt {
+			dt = elapsed
 		}
+		elapsed -= dt
+		// overtakes change the order, every substep needs the leaders first
+		sort.SliceStable(followers, func(i, j int) bool {
+			return followers[i].pos > followers[j].pos
+		})
+		changeLanes(followers, distance, lanes)
+		followers = followLeaders(followers, dt.Hours(), distance, lanes, exit)
+	}
+
+	vehicles := make([]*vehicle, len(followers))
+	speedSum := 0.0
+	for i, f := range followers {
+		f.store(distance)
+		vehicles[i] = f.v
+		speedSum += f.speed
 	}
+
 	r.vehiclesMu.Lock()
-	r.vehicles = nv
+	r.vehicles = vehicles
 	r.vehiclesMu.Unlock()
-}
-func moveVehicle(v *vehicle, timeElapsed float64, maxSpeed float64, distance float64) (shouldRoute bool) {
-	v.propertyMu.RLock()
-	vd := v.VehicleData
-	v.propertyMu.RUnlock()
-
-	speed := math.Min(vd.PreferredSpeed, maxSpeed)
-	vd.Progress += speed * timeElapsed / distance
-	shouldRoute = vd.Progress >= 1
 
-	v.propertyMu.Lock()
-	v.VehicleData.Progress = vd.Progress
-	v.propertyMu.Unlock()
-	return
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+	r.metrics.Exited += exited
+	r.dwellSum += dwellSum
+	if r.metrics.Exited > 0 {
+		r.metrics.MeanDwellTime = r.dwellSum / time.Duration(r.metrics.Exited)
+	}
+	r.metrics.Vehicles = len(vehicles)
+	r.metrics.FlowSpeed = speed
+	r.metrics.AverageSpeed = 0
+	if len(vehicles) > 0 {
+		r.metrics.AverageSpeed = speedSum / float64(len(vehicles))
+	}
+	r.metrics.Density = 0
+	if distance > 0 {
+		r.metrics.Density = float64(len(vehicles)) / distance
+	}
 }
+
 func (r *road) route(v *vehicle) {
+	lane := r.entryLane()
 	v.propertyMu.Lock()
-	v.VehicleData.Progress = 0
+	v.VehicleData.Progress, v.VehicleData.CurrentSpeed, v.VehicleData.Lane = 0, 0, lane
 	v.propertyMu.Unlock()
-	r.vehicleQueue.In() <- v
+	r.addVehicle(v, true)
+	r.sim.emit(api.RoadEntered, v, r.src, r)
+}
+
+// flowSpeed reduces the free-flow speed as the density, in vehicles per km per lane, rises.
+// It follows Greenshields' model, where the flow peaks at capacity when the density is half of the jam density.
+// The speed never drops below minFlowSpeedRatio of the free-flow speed, so a jammed road still drains
+func flowSpeed(freeSpeed, capacity, density float64) float64 {
+	if capacity <= 0 || freeSpeed <= 0 {
+		return freeSpeed
+	}
+	jamDensity := 4 * capacity / freeSpeed
+	return freeSpeed * math.Max(1-density/jamDensity, minFlowSpeedRatio)
+}
+
+// flowSpeed is the speed allowed by the density on the road, reduced by the incidents at now. It is zero when the road is closed
+func (r *road) flowSpeed(now time.Duration, density float64) float64 {
+	left := r.sim.capacityLeft(r.src.Name(), r.dst.Name(), now)
+	if left <= 0 {
+		return 0
+	}
+	freeSpeed, capacity := r.MaxSpeed(), r.Capacity()
+	if capacity > 0 {
+		capacity *= left
+	} else {
+		freeSpeed *= left
+	}
+	return flowSpeed(freeSpeed, capacity, density)
+}
+
+// entryLane returns the lane with the most room at the start of the road
+func (r *road) entryLane() int {
+	lanes := r.Lanes()
+	rear := make([]float64, lanes)
+	for i := range rear {
+		rear[i] = math.Inf(1)
+	}
+	r.vehiclesMu.RLock()
+	for _, v := range r.vehicles {
+		if l := v.Lane(); l < lanes {
+			rear[l] = math.Min(rear[l], v.Progress())
+		}
+	}
+	r.vehiclesMu.RUnlock()
+	best := 0
+	for l := range rear {
+		if rear[l] > rear[best] {
+			best = l
+		}
+	}
+	return best
+}
+
+// strandVehicles drops the vehicles of a removed road in its source city
+func (r *road) strandVehicles() {
+	r.vehiclesMu.Lock()
+	vehicles := r.vehicles
+	r.vehicles = nil
+	r.vehiclesMu.Unlock()
+	for _, v := range vehicles {
+		r.src.strand(v)
+	}
+}
+
+// addVehicle puts v on the road keeping its progress, entering counts it in the Entered metric.
+// Vehicles restored on the road are not entering it
+func (r *road) addVehicle(v *vehicle, entering bool) {
+	v.roadEntered = r.sim.clock.Now()
+	r.vehiclesMu.Lock()
+	r.vehicles = append(r.vehicles, v)
+	n := len(r.vehicles)
+	r.vehiclesMu.Unlock()
+
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+	if entering {
+		r.metrics.Entered++
+	}
+	r.metrics.Vehicles = n
 }
 
 func (r *road) MaxSpeed() float64 {
@@ -120,6 +244,35 @@ func (r *road) SetMaxSpeed(f float64) {
 	defer r.propertyMu.Unlock()
 	r.RoadData.MaxSpeed = f
 }
+func (r *road) Capacity() float64 {
+	r.propertyMu.RLock()
+	defer r.propertyMu.RUnlock()
+	return r.RoadData.Capacity
+}
+func (r *road) SetCapacity(capacity float64) {
+	r.propertyMu.Lock()
+	defer r.propertyMu.Unlock()
+	r.RoadData.Capacity = capacity
+}
+func (r *road) Lanes() int {
+	r.propertyMu.RLock()
+	defer r.propertyMu.RUnlock()
+	return r.RoadData.Lanes
+}
+func (r *road) SetLanes(lanes int) {
+	if lanes < 1 {
+		lanes = 1
+	}
+	r.propertyMu.Lock()
+	defer r.propertyMu.Unlock()
+	r.RoadData.Lanes = lanes
+}
+func (r *road) CapacityLeft() float64 {
+	return r.sim.capacityLeft(r.src.Name(), r.dst.Name(), r.sim.clock.Now())
+}
+func (r *road) Closed() bool {
+	return r.CapacityLeft() <= 0
+}
 func (r *road) Vehicles() []api.Vehicle {
 	r.vehiclesMu.RLock()
 	vehicles := make([]*vehicle, len(r.vehicles))
@@ -131,6 +284,16 @@ func (r *road) Vehicles() []api.Vehicle {
 	}
 	return vs
 }
+func (r *road) Metrics() api.RoadMetrics {
+	r.metricsMu.RLock()
+	defer r.metricsMu.RUnlock()
+	return r.metrics
+}
+func (r *road) History() []api.RoadSample {
+	r.metricsMu.RLock()
+	defer r.metricsMu.RUnlock()
+	return r.history.Slice()
+}
 func (r *road) Src() api.City {
 	return r.src
 }
@@ -141,13 +304,55 @@ func (r *road) Dst() api.City {
 func (r *road) NodeIndex() int {
 	return r.sim.cityIndex(r.dst.Name())
 }
+
+// Weight is the cost of the road scaled by costScale, at least 1 so that every road counts
 func (r *road) Weight() int {
+	r.sim.citiesMu.RLock()
+	cities := len(r.sim.cities)
+	r.sim.citiesMu.RUnlock()
+	return r.weight(r.sim.RouteCost(), cities)
+}
+
+// weight is the cost of the road scaled by costScale. It is bounded by maxWeight, so that the sum along
+// a path through the given number of cities cannot overflow
+func (r *road) weight(routeCost api.RouteCost, cities int) int {
+	weight := math.Ceil(r.cost(routeCost) * costScale)
+	if limit := maxWeight(cities); weight >= float64(limit) {
+		return limit
+	}
+	return int(math.Max(weight, 1))
+}
+
+// maxWeight bounds the weight of impassable roads, a path visits every city at most once
+func maxWeight(cities int) int {
+	return math.MaxInt / (cities + 1)
+}
+
+// cost is the length of the road in km or the time to travel it in hours, it is infinite while the road is closed
+func (r *road) cost(routeCost api.RouteCost) float64 {
+	if r.Closed() {
+		return math.Inf(1)
+	}
 	distance := api.Distance(r.src.Position(), r.dst.Position())
-	tripTime := distance / r.MaxSpeed()
-	r.vehiclesMu.RLock()
-	vNum := float64(len(r.vehicles))
-	r.vehiclesMu.RUnlock()
-	vehiclePerKm := vNum / (distance)
-	tripTime *= vehiclePerKm
-	return int(tripTime)
+	if routeCost == api.RoadLength {
+		return distance
+	}
+	maxSpeed := r.MaxSpeed()
+	speed := maxSpeed
+	if routeCost == api.CongestedTime {
+		r.vehiclesMu.RLock()
+		vehicles := len(r.vehicles)
+		r.vehiclesMu.RUnlock()
+		if distance > 0 {
+			speed = r.flowSpeed(r.sim.clock.Now(), float64(vehicles)/distance/float64(r.Lanes()))
+		}
+		if m := r.Metrics(); vehicles > 0 && m.Vehicles > 0 {
+			speed = math.Min(speed, m.AverageSpeed)
+		}
+		speed = math.Max(speed, maxSpeed*minFlowSpeedRatio)
+	}
+	if speed <= 0 {
+		return math.Inf(1)
+	}
+	return distance / speed
 }
@@ -0,0 +1,50 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"github.com/bisoncorp/graph"
+)
+
+// costGraph is the road network weighted by a RouteCost, it is only used while citiesMu is held
+type costGraph struct {
+	s    *simulation
+	cost api.RouteCost
+}
+
+func (g costGraph) Nodes() []graph.Node {
+	nodes := make([]graph.Node, len(g.s.cities))
+	for i, c := range g.s.cities {
+		nodes[i] = costNode{g: g, c: c}
+	}
+	return nodes
+}
+
+type costNode struct {
+	g costGraph
+	c *city
+}
+
+// Links leaves out the closed roads
+func (n costNode) Links() []graph.Link {
+	n.c.roadsMu.RLock()
+	defer n.c.roadsMu.RUnlock()
+	links := make([]graph.Link, 0, len(n.c.roadsOut))
+	for _, r := range n.c.roadsOut {
+		if !r.Closed() {
+			links = append(links, costLink{g: n.g, r: r})
+		}
+	}
+	return links
+}
+
+type costLink struct {
+	g costGraph
+	r *road
+}
+
+func (l costLink) NodeIndex() int {
+	return l.g.s.cityMap[l.r.dst.Name()]
+}
+func (l costLink) Weight() int {
+	return l.r.weight(l.g.cost, len(l.g.s.cities))
+}
@@ -2,20 +2,28 @@ package game
 
 import (
 	"fmt"
+	"github.com/bisoncorp/autostrade/game/utils"
 	api "github.com/bisoncorp/autostrade/gameapi"
 	"github.com/bisoncorp/graph/dijkstra"
 	"github.com/bisoncorp/graph"
+	"hash/fnv"
+	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type simulation struct {
 	speed      float64
+	routeCost  api.RouteCost
+	compliance float64
 	propertyMu sync.RWMutex
 
-	lastPlateCh <-chan api.Plate
-	nextPlateCh <-chan api.Plate
+	// nextPlate is the plate of the next generated vehicle
+	nextPlate   api.Plate
+	nextPlateMu sync.Mutex
 
 	cities   []*city
 	cityMap  map[string]int
@@ -25,72 +33,332 @@ type simulation struct {
 	roadMap map[string]int
 	roadsMu sync.RWMutex
 
+	// demand is the origin-destination matrix, the cities without demand pick uniformly random destinations.
+	// demandMu is taken after citiesMu
+	demand   map[api.ODPair]float64
+	demandMu sync.RWMutex
+
+	// lines are the public transport lines in the order they were added, linesMu is taken after citiesMu
+	lines   []*line
+	linesMu sync.RWMutex
+
+	// incidents are sorted by start, incidentsMu is taken after every other lock of the simulation
+	incidents   []api.Incident
+	incidentsMu sync.RWMutex
+
+	clock  *clock
+	loop   api.Runnable
+	events eventBus
+	stats  *statsRecorder
+
+	seed int64
+
 	running atomic.Bool
 }
 
 func newSimulation(data api.SimulationData) *simulation {
 	s := &simulation{
-		speed:   data.Speed,
-		cities:  make([]*city, 0),
-		cityMap: make(map[string]int),
-		roads:   make([]*road, 0),
-		roadMap: make(map[string]int),
+		speed:      data.Speed,
+		routeCost:  data.RouteCost,
+		compliance: data.Compliance,
+		cities:     make([]*city, 0),
+		cityMap:    make(map[string]int),
+		roads:      make([]*road, 0),
+		roadMap:    make(map[string]int),
+		demand:     make(map[api.ODPair]float64),
+		clock:      newClock(data.Clock),
+		seed:       data.Seed,
+	}
+	if s.seed == 0 {
+		s.seed = time.Now().UnixNano()
 	}
+	s.loop = utils.NewBaseRunnable(s)
+	s.stats = newStatsRecorder(s.clock.Now(), statsWindow)
 
 	cityHook := make([]api.City, len(data.Cities))
 	for i := 0; i < len(data.Cities); i++ {
 		cityHook[i] = s.AddCity(data.Cities[i])
 	}
+	roadHook := make([]api.Road, len(data.Roads))
 	for i := 0; i < len(data.Roads); i++ {
 		rd := data.Roads[i]
-		s.AddOneWayRoad(cityHook[rd.SrcIndex], cityHook[rd.DstIndex], rd.RoadData)
+		roadHook[i] = s.AddOneWayRoad(cityHook[rd.SrcIndex], cityHook[rd.DstIndex], rd.RoadData)
+	}
+	for i := 0; i < len(data.Vehicles); i++ {
+		vd := data.Vehicles[i]
+		if vd.RoadIndex < 0 || vd.RoadIndex >= len(roadHook) || roadHook[vd.RoadIndex] == nil {
+			continue
+		}
+		r := roadHook[vd.RoadIndex].(*road)
+		r.addVehicle(newVehicle(vd.VehicleData, s.restoreTrip(vd.Itinerary, r)), false)
+	}
+	for _, vd := range data.Queued {
+		if vd.CityIndex < 0 || vd.CityIndex >= len(cityHook) || cityHook[vd.CityIndex] == nil {
+			continue
+		}
+		c := cityHook[vd.CityIndex].(*city)
+		c.restoreQueued(newVehicle(vd.VehicleData, s.restoreCityTrip(vd.Itinerary, c)))
 	}
-	s.startPlateGenerator(data.LastPlate)
+	for _, vd := range data.Dwelling {
+		if vd.CityIndex < 0 || vd.CityIndex >= len(cityHook) || cityHook[vd.CityIndex] == nil {
+			continue
+		}
+		c := cityHook[vd.CityIndex].(*city)
+		c.restoreDwelling(newVehicle(vd.VehicleData, s.restoreCityTrip(vd.Itinerary, c)), s.clock.Now()+vd.Remaining)
+	}
+	s.SetDemand(data.Demand)
+	for _, ld := range data.Lines {
+		s.AddLine(ld)
+	}
+	s.SetIncidents(data.Incidents)
+	s.nextPlate = data.LastPlate
 	return s
 }
 
+// Update runs the ticks due since the previous frame, it is driven by loop while the simulation is running
+func (s *simulation) Update(uint64) {
+	for _, elapsed := range s.clock.wait(s.Speed()) {
+		s.tick(elapsed, true)
+	}
+}
+
+// tick updates roads, cities and then lines by elapsed of simulated time, always in the same order.
+// When onlyRunning is set the stopped roads and cities are skipped
+func (s *simulation) tick(elapsed time.Duration, onlyRunning bool) {
+	now := s.clock.Now()
+
+	s.roadsMu.RLock()
+	roads := make([]*road, len(s.roads))
+	copy(roads, s.roads)
+	s.roadsMu.RUnlock()
+
+	s.citiesMu.RLock()
+	cities := make([]*city, len(s.cities))
+	copy(cities, s.cities)
+	s.citiesMu.RUnlock()
+
+	s.linesMu.RLock()
+	lines := make([]*line, len(s.lines))
+	copy(lines, s.lines)
+	s.linesMu.RUnlock()
+
+	s.pruneIncidents(now)
+	for _, r := range roads {
+		if !onlyRunning || r.Running() {
+			r.Update(now, elapsed)
+		}
+	}
+	for _, c := range cities {
+		if !onlyRunning || c.Running() {
+			c.Update(now, elapsed)
+		}
+	}
+	for _, l := range lines {
+		l.Update(now, elapsed)
+	}
+	s.clock.advance(elapsed)
+}
+
 func (s *simulation) cityIndex(name string) int {
 	s.citiesMu.RLock()
 	defer s.citiesMu.RUnlock()
 	return s.cityMap[name]
 }
-func (s *simulation) generateTrip(src string, _ float64) api.Trip {
+
+// randSource returns a random stream derived from the simulation seed and the stream name,
+// so that a stream does not depend on the order in which the others are created
+func (s *simulation) randSource(name string) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return rand.New(rand.NewSource(s.seed ^ int64(h.Sum64())))
+}
+
+// generateTrip returns a trip from src to a random city reachable from it minimising cost,
+// ok is false if there is none
+func (s *simulation) generateTrip(src string, rng *rand.Rand, cost api.RouteCost) (trip api.Trip, ok bool) {
 	s.citiesMu.RLock()
 	defer s.citiesMu.RUnlock()
-	srcIndex, dstIndex := s.cityIndex(src), -1
-	maxIndex := len(s.cities)
-	for {
-		dstIndex = rand.Intn(maxIndex)
-		if srcIndex != dstIndex {
+	srcIndex, exist := s.cityMap[src]
+	if !exist {
+		return api.Trip{}, false
+	}
+	reachable := s.reachableFrom(srcIndex)
+	dstIndex := -1
+	if weights := s.demandFrom(src); len(weights) > 0 {
+		dstIndex = s.pickDestination(reachable, weights, rng)
+	} else if len(reachable) > 0 {
+		dstIndex = reachable[rng.Intn(len(reachable))]
+	}
+	if dstIndex < 0 {
+		return api.Trip{}, false
+	}
+	path := s.shortestPath(srcIndex, dstIndex, cost)
+	return api.NewTrip(path), len(path) > 1
+}
+
+// pickDestination returns one of the reachable city indexes with a probability proportional to its weight,
+// -1 if no reachable city has weight. citiesMu must be held
+func (s *simulation) pickDestination(reachable []int, weights map[string]float64, rng *rand.Rand) int {
+	total := 0.0
+	for _, i := range reachable {
+		total += weights[s.cities[i].Name()]
+	}
+	if total <= 0 {
+		return -1
+	}
+	x := rng.Float64() * total
+	picked := -1
+	for _, i := range reachable {
+		w := weights[s.cities[i].Name()]
+		if w <= 0 {
+			continue
+		}
+		// the last weighted city is kept when rounding leaves x past the total
+		picked = i
+		if x < w {
 			break
 		}
+		x -= w
+	}
+	return picked
+}
+
+// demandFrom returns the weights of the destinations of origin
+func (s *simulation) demandFrom(origin string) map[string]float64 {
+	s.demandMu.RLock()
+	defer s.demandMu.RUnlock()
+	weights := make(map[string]float64)
+	for pair, w := range s.demand {
+		if pair.Origin == origin {
+			weights[pair.Destination] = w
+		}
+	}
+	return weights
+}
+
+// reachableFrom returns the indexes of the cities reachable from srcIndex without closed roads, in index order.
+// citiesMu must be held
+func (s *simulation) reachableFrom(srcIndex int) []int {
+	visited := make([]bool, len(s.cities))
+	visited[srcIndex] = true
+	stack := []int{srcIndex}
+	for len(stack) > 0 {
+		c := s.cities[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		c.roadsMu.RLock()
+		for _, r := range c.roadsOut {
+			if i, exist := s.cityMap[r.dst.Name()]; exist && !visited[i] && !r.Closed() {
+				visited[i] = true
+				stack = append(stack, i)
+			}
+		}
+		c.roadsMu.RUnlock()
+	}
+	reachable := make([]int, 0, len(s.cities))
+	for i := range visited {
+		if visited[i] && i != srcIndex {
+			reachable = append(reachable, i)
+		}
+	}
+	return reachable
+}
+
+// shortestPath returns the cities of the cheapest path between two city indexes without closed roads,
+// it is empty when there is none. InheritedCost is the RouteCost of the simulation. citiesMu must be held
+func (s *simulation) shortestPath(srcIndex, dstIndex int, cost api.RouteCost) []api.City {
+	if cost == api.InheritedCost {
+		cost = s.RouteCost()
+	}
+	path := dijkstra.ShortestPath(costGraph{s: s, cost: cost}, srcIndex, dstIndex)
+	if len(path) == 0 || path[0] != srcIndex || path[len(path)-1] != dstIndex {
+		return nil
 	}
-	path := dijkstra.ShortestPath(s, srcIndex, dstIndex)
 	cities := make([]api.City, len(path))
 	for i := 0; i < len(path); i++ {
 		cities[i] = s.cities[path[i]]
+		if i > 0 && !s.cities[path[i-1]].open(cities[i].Name()) {
+			return nil
+		}
 	}
-	return api.NewTrip(cities)
+	return cities
 }
-func (s *simulation) generatePlate() string {
-	return (<-s.nextPlateCh).String()
-}
-func (s *simulation) startPlateGenerator(initialPlate api.Plate) {
-	nextPlateCh, lastPlateCh := make(chan api.Plate, 16), make(chan api.Plate)
-	s.nextPlateCh, s.lastPlateCh = nextPlateCh, lastPlateCh
-	go func() {
-		plate := initialPlate
-		for {
-			select {
-			case nextPlateCh <- plate:
-				plate = plate.Next()
-			case lastPlateCh <- plate:
-			}
+
+// replan returns the cheapest path from src to dst using the current road costs,
+// it is empty when dst has been removed or cannot be reached
+func (s *simulation) replan(src, dst string, cost api.RouteCost) []api.City {
+	s.citiesMu.RLock()
+	defer s.citiesMu.RUnlock()
+	srcIndex, srcExist := s.cityMap[src]
+	dstIndex, dstExist := s.cityMap[dst]
+	if !srcExist || !dstExist {
+		return nil
+	}
+	return s.shortestPath(srcIndex, dstIndex, cost)
+}
+
+// restoreTrip rebuilds the trip of a vehicle travelling on r, when the itinerary
+// does not match the simulation the vehicle just reaches the end of r
+func (s *simulation) restoreTrip(data api.TripData, r *road) api.Trip {
+	fallback := api.NewTripAt([]api.City{r.src, r.dst}, 1)
+	if data.Index < 1 || data.Index >= len(data.Cities) {
+		return fallback
+	}
+	if data.Cities[data.Index-1] != r.src.Name() || data.Cities[data.Index] != r.dst.Name() {
+		return fallback
+	}
+	cities := s.tripCities(data.Cities)
+	if cities == nil {
+		return fallback
+	}
+	return api.NewTripAt(cities, data.Index)
+}
+
+// restoreCityTrip rebuilds the trip of a vehicle in c, when the itinerary
+// does not match the simulation the trip of the vehicle ends in c
+func (s *simulation) restoreCityTrip(data api.TripData, c *city) api.Trip {
+	fallback := api.NewTripAt([]api.City{c}, 0)
+	if data.Index < 0 || data.Index >= len(data.Cities) || data.Cities[data.Index] != c.Name() {
+		return fallback
+	}
+	cities := s.tripCities(data.Cities)
+	if cities == nil {
+		return fallback
+	}
+	return api.NewTripAt(cities, data.Index)
+}
+
+// tripCities returns the named cities, nil if one of them is missing
+func (s *simulation) tripCities(names []string) []api.City {
+	cities := make([]api.City, len(names))
+	for i, name := range names {
+		if cities[i] = s.City(name); cities[i] == nil {
+			return nil
 		}
-	}()
+	}
+	return cities
+}
+func (s *simulation) generatePlate() string {
+	s.nextPlateMu.Lock()
+	defer s.nextPlateMu.Unlock()
+	plate := s.nextPlate
+	s.nextPlate = plate.Next()
+	return plate.String()
+}
+func (s *simulation) lastPlate() api.Plate {
+	s.nextPlateMu.Lock()
+	defer s.nextPlateMu.Unlock()
+	return s.nextPlate
 }
 
 func (s *simulation) AddCity(data api.CityData) api.City {
+	c := s.addCity(data)
+	if c == nil {
+		return nil
+	}
+	s.emit(api.CityAdded, nil, c, nil)
+	return c
+}
+func (s *simulation) addCity(data api.CityData) *city {
 	s.citiesMu.Lock()
 	defer s.citiesMu.Unlock()
 
@@ -104,6 +372,16 @@ func (s *simulation) AddCity(data api.CityData) api.City {
 	return c
 }
 func (s *simulation) RemoveCity(c api.City) {
+	city0, roads := s.removeCity(c)
+	for _, r := range roads {
+		r.strandVehicles()
+		s.emit(api.RoadRemoved, nil, nil, r)
+	}
+	if city0 != nil {
+		s.emit(api.CityRemoved, nil, city0, nil)
+	}
+}
+func (s *simulation) removeCity(c api.City) (removed *city, removedRoads []*road) {
 	s.citiesMu.Lock()
 	defer s.citiesMu.Unlock()
 
@@ -118,15 +396,38 @@ func (s *simulation) RemoveCity(c api.City) {
 	roads := append(roadsIn, roadsOut...)
 	city0.Stop()
 	for _, r := range roads {
-		s.RemoveRoad(r)
+		if r0 := s.removeRoad(r); r0 != nil {
+			removedRoads = append(removedRoads, r0)
+		}
+	}
+
+	s.demandMu.Lock()
+	for pair := range s.demand {
+		if pair.Origin == city0.Name() || pair.Destination == city0.Name() {
+			delete(s.demand, pair)
+		}
+	}
+	s.demandMu.Unlock()
+
+	// a line cannot skip a stop, the lines serving the city are removed
+	s.linesMu.Lock()
+	lines := s.lines[:0]
+	for _, l := range s.lines {
+		if !l.stopsIn(city0.Name()) {
+			lines = append(lines, l)
+		}
 	}
+	s.lines = lines
+	s.linesMu.Unlock()
 
+	delete(s.cityMap, city0.Name())
 	for k, v := range s.cityMap {
 		if v > index {
 			s.cityMap[k] = v - 1
 		}
 	}
 	s.cities = append(s.cities[:index], s.cities[index+1:]...)
+	return city0, removedRoads
 }
 
 func (s *simulation) AddRoad(a, b api.City, data api.RoadData) (atob api.Road, btoa api.Road) {
@@ -135,6 +436,14 @@ func (s *simulation) AddRoad(a, b api.City, data api.RoadData) (atob api.Road, b
 	return
 }
 func (s *simulation) AddOneWayRoad(src, dst api.City, data api.RoadData) api.Road {
+	r := s.addOneWayRoad(src, dst, data)
+	if r == nil {
+		return nil
+	}
+	s.emit(api.RoadAdded, nil, nil, r)
+	return r
+}
+func (s *simulation) addOneWayRoad(src, dst api.City, data api.RoadData) *road {
 	s.citiesMu.RLock()
 	defer s.citiesMu.RUnlock()
 	indexSrc, existSrc := s.cityMap[src.Name()]
@@ -162,12 +471,18 @@ func (s *simulation) AddOneWayRoad(src, dst api.City, data api.RoadData) api.Roa
 	return r
 }
 func (s *simulation) RemoveRoad(r api.Road) {
+	if r0 := s.removeRoad(r); r0 != nil {
+		r0.strandVehicles()
+		s.emit(api.RoadRemoved, nil, nil, r0)
+	}
+}
+func (s *simulation) removeRoad(r api.Road) *road {
 	s.roadsMu.Lock()
 	defer s.roadsMu.Unlock()
 	name := roadName(r.Src().Name(), r.Dst().Name())
 	index, existRoad := s.roadMap[name]
 	if !existRoad {
-		return
+		return nil
 	}
 
 	r0 := s.roads[index]
@@ -175,12 +490,24 @@ func (s *simulation) RemoveRoad(r api.Road) {
 	r0.Src().(*city).remRoadOut(r0)
 	r0.Dst().(*city).remRoadIn(r0)
 
+	s.incidentsMu.Lock()
+	incidents := s.incidents[:0]
+	for _, i := range s.incidents {
+		if i.Src != r0.src.Name() || i.Dst != r0.dst.Name() {
+			incidents = append(incidents, i)
+		}
+	}
+	s.incidents = incidents
+	s.incidentsMu.Unlock()
+
+	delete(s.roadMap, name)
 	for k, v := range s.roadMap {
 		if v > index {
 			s.roadMap[k] = v - 1
 		}
 	}
 	s.roads = append(s.roads[:index], s.roads[index+1:]...)
+	return r0
 }
 
 func (s *simulation) City(name string) api.City {
@@ -204,6 +531,20 @@ func (s *simulation) Road(a, b string) (atob, btoa api.Road) {
 	return
 }
 func (s *simulation) Vehicle(plate string) api.Vehicle {
+	s.citiesMu.RLock()
+	defer s.citiesMu.RUnlock()
+	for _, c := range s.cities {
+		for _, v := range c.queued() {
+			if v.Plate() == plate {
+				return v
+			}
+		}
+		for _, d := range c.dwellingVehicles() {
+			if d.v.Plate() == plate {
+				return d.v
+			}
+		}
+	}
 	s.roadsMu.RLock()
 	defer s.roadsMu.RUnlock()
 	for _, r := range s.roads {
@@ -217,6 +558,70 @@ func (s *simulation) Vehicle(plate string) api.Vehicle {
 	return nil
 }
 
+func (s *simulation) AddLine(data api.LineData) api.Line {
+	s.citiesMu.RLock()
+	defer s.citiesMu.RUnlock()
+	if data.Name == "" || len(data.Stops) < 2 {
+		return nil
+	}
+	for i, stop := range data.Stops {
+		if _, exist := s.cityMap[stop]; !exist {
+			return nil
+		}
+		if i > 0 && s.cities[s.cityMap[data.Stops[i-1]]].roadTo(stop) == nil {
+			return nil
+		}
+	}
+
+	s.linesMu.Lock()
+	defer s.linesMu.Unlock()
+	for _, l := range s.lines {
+		if l.Name() == data.Name {
+			return nil
+		}
+	}
+	l := newLine(data, s)
+	s.lines = append(s.lines, l)
+	return l
+}
+func (s *simulation) RemoveLine(l api.Line) {
+	s.linesMu.Lock()
+	defer s.linesMu.Unlock()
+	for i, l0 := range s.lines {
+		if l0.Name() == l.Name() {
+			s.lines = append(s.lines[:i], s.lines[i+1:]...)
+			return
+		}
+	}
+}
+func (s *simulation) Line(name string) api.Line {
+	if l := s.line(name); l != nil {
+		return l
+	}
+	return nil
+}
+
+// line returns the named line, nil if there is none
+func (s *simulation) line(name string) *line {
+	s.linesMu.RLock()
+	defer s.linesMu.RUnlock()
+	for _, l := range s.lines {
+		if l.Name() == name {
+			return l
+		}
+	}
+	return nil
+}
+func (s *simulation) Lines() []api.Line {
+	s.linesMu.RLock()
+	defer s.linesMu.RUnlock()
+	lines := make([]api.Line, len(s.lines))
+	for i, l := range s.lines {
+		lines[i] = l
+	}
+	return lines
+}
+
 func (s *simulation) PackData() api.SimulationData {
 	s.citiesMu.RLock()
 	defer s.citiesMu.RUnlock()
@@ -224,9 +629,16 @@ func (s *simulation) PackData() api.SimulationData {
 	defer s.roadsMu.RUnlock()
 
 	data := api.SimulationData{
-		Speed:     s.Speed(),
-		LastPlate: <-s.lastPlateCh,
-		Cities:    make([]api.CityData, 0, len(s.cities)),
+		Version:    api.FormatVersion,
+		Speed:      s.Speed(),
+		Clock:      s.clock.packData(),
+		Seed:       s.seed,
+		LastPlate:  s.lastPlate(),
+		RouteCost:  s.RouteCost(),
+		Compliance: s.Compliance(),
+		Demand:     s.Demand(),
+		Incidents:  s.Incidents(),
+		Cities:     make([]api.CityData, 0, len(s.cities)),
 		Roads: make([]struct {
 			api.RoadData
 			SrcIndex, DstIndex int
@@ -237,11 +649,26 @@ func (s *simulation) PackData() api.SimulationData {
 		}, 0),
 	}
 
-	for _, c := range s.cities {
+	for i, c := range s.cities {
 		c.propertyMu.RLock()
 		cd := c.CityData
 		c.propertyMu.RUnlock()
+		cd.Profile = c.Profile()
+		cd.Fleet = c.Fleet()
 		data.Cities = append(data.Cities, cd)
+		for _, v := range c.queued() {
+			data.Queued = append(data.Queued, struct {
+				api.VehicleData
+				CityIndex int
+			}{VehicleData: v.packData(), CityIndex: i})
+		}
+		for _, d := range c.dwellingVehicles() {
+			data.Dwelling = append(data.Dwelling, struct {
+				api.VehicleData
+				CityIndex int
+				Remaining time.Duration
+			}{VehicleData: d.v.packData(), CityIndex: i, Remaining: d.until - data.Clock.Now})
+		}
 	}
 
 	for _, r := range s.roads {
@@ -260,9 +687,7 @@ func (s *simulation) PackData() api.SimulationData {
 		copy(vehicles, r.vehicles)
 		r.vehiclesMu.RUnlock()
 		for _, v := range vehicles {
-			v.propertyMu.RLock()
-			vd := v.VehicleData
-			v.propertyMu.RUnlock()
+			vd := v.packData()
 			data.Vehicles = append(data.Vehicles, struct {
 				api.VehicleData
 				RoadIndex int
@@ -270,9 +695,122 @@ func (s *simulation) PackData() api.SimulationData {
 		}
 	}
 
+	s.linesMu.RLock()
+	defer s.linesMu.RUnlock()
+	for _, l := range s.lines {
+		data.Lines = append(data.Lines, l.packData())
+	}
+
 	return data
 }
 
+func (s *simulation) Subscribe(fn api.EventCallback, types ...api.EventType) (unsubscribe func()) {
+	return s.events.subscribe(fn, types...)
+}
+
+// emit sends an event at the current simulated time, nil entities are left out of the event
+func (s *simulation) emit(t api.EventType, v *vehicle, c *city, r *road) {
+	e := api.Event{Type: t, Time: s.clock.Now()}
+	if v != nil {
+		e.Vehicle = v
+	}
+	if c != nil {
+		e.City = c
+	}
+	if r != nil {
+		e.Road = r
+	}
+	s.events.emit(e)
+}
+
+func (s *simulation) Stats() api.Stats {
+	return s.stats.snapshot(s.clock.Now())
+}
+
+func (s *simulation) Clock() api.Clock {
+	return s.clock
+}
+func (s *simulation) Advance(d time.Duration) {
+	if s.Running() {
+		return
+	}
+	step := s.clock.Step()
+	for d > 0 {
+		elapsed := step
+		if d < step {
+			elapsed = d
+		}
+		s.tick(elapsed, false)
+		d -= elapsed
+	}
+}
+
+// capacityLeft is the fraction of the capacity of the road from src to dst left by the incidents at now,
+// only the incidents started by now are scanned
+func (s *simulation) capacityLeft(src, dst string, now time.Duration) float64 {
+	s.incidentsMu.RLock()
+	defer s.incidentsMu.RUnlock()
+	started := sort.Search(len(s.incidents), func(i int) bool { return s.incidents[i].Start > now })
+	return api.CapacityLeft(s.incidents[:started], src, dst, now)
+}
+
+// pruneIncidents drops the incidents cleared by now
+func (s *simulation) pruneIncidents(now time.Duration) {
+	s.incidentsMu.Lock()
+	defer s.incidentsMu.Unlock()
+	incidents := s.incidents[:0]
+	for _, i := range s.incidents {
+		if i.End > now {
+			incidents = append(incidents, i)
+		}
+	}
+	for i := len(incidents); i < len(s.incidents); i++ {
+		s.incidents[i] = api.Incident{}
+	}
+	s.incidents = incidents
+}
+func (s *simulation) Incidents() []api.Incident {
+	s.incidentsMu.RLock()
+	defer s.incidentsMu.RUnlock()
+	return append([]api.Incident(nil), s.incidents...)
+}
+func (s *simulation) SetIncidents(incidents []api.Incident) {
+	s.roadsMu.RLock()
+	defer s.roadsMu.RUnlock()
+	valid := make([]api.Incident, 0, len(incidents))
+	for _, i := range incidents {
+		if s.validIncident(i) {
+			valid = append(valid, i)
+		}
+	}
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].Start < valid[j].Start })
+
+	s.incidentsMu.Lock()
+	defer s.incidentsMu.Unlock()
+	s.incidents = valid
+}
+func (s *simulation) AddIncident(incident api.Incident) (ok bool) {
+	s.roadsMu.RLock()
+	defer s.roadsMu.RUnlock()
+	if !s.validIncident(incident) {
+		return false
+	}
+
+	s.incidentsMu.Lock()
+	defer s.incidentsMu.Unlock()
+	index := sort.Search(len(s.incidents), func(i int) bool { return s.incidents[i].Start > incident.Start })
+	s.incidents = append(s.incidents, api.Incident{})
+	copy(s.incidents[index+1:], s.incidents[index:])
+	s.incidents[index] = incident
+	return true
+}
+
+// validIncident reports whether the incident is on a road and has a window, roadsMu must be held
+func (s *simulation) validIncident(i api.Incident) bool {
+	_, exist := s.roadMap[roadName(i.Src, i.Dst)]
+	return exist && i.Start >= 0 && i.End > i.Start && i.Capacity >= 0 && i.Capacity < 1
+}
+
 func (s *simulation) Speed() float64 {
 	s.propertyMu.RLock()
 	defer s.propertyMu.RUnlock()
@@ -284,6 +822,61 @@ func (s *simulation) SetSpeed(speed float64) {
 	s.speed = speed
 }
 
+func (s *simulation) Demand() []api.Demand {
+	s.demandMu.RLock()
+	demand := make([]api.Demand, 0, len(s.demand))
+	for pair, w := range s.demand {
+		demand = append(demand, api.Demand{ODPair: pair, Weight: w})
+	}
+	s.demandMu.RUnlock()
+	sort.Slice(demand, func(i, j int) bool {
+		if demand[i].Origin != demand[j].Origin {
+			return demand[i].Origin < demand[j].Origin
+		}
+		return demand[i].Destination < demand[j].Destination
+	})
+	return demand
+}
+func (s *simulation) SetDemand(demand []api.Demand) {
+	// the cities cannot be removed until the matrix is replaced
+	s.citiesMu.RLock()
+	defer s.citiesMu.RUnlock()
+	pairs := make(map[api.ODPair]float64, len(demand))
+	for _, d := range demand {
+		_, originExist := s.cityMap[d.Origin]
+		_, destinationExist := s.cityMap[d.Destination]
+		if d.Weight > 0 && d.Origin != d.Destination && originExist && destinationExist {
+			pairs[d.ODPair] = d.Weight
+		}
+	}
+
+	s.demandMu.Lock()
+	defer s.demandMu.Unlock()
+	s.demand = pairs
+}
+
+func (s *simulation) RouteCost() api.RouteCost {
+	s.propertyMu.RLock()
+	defer s.propertyMu.RUnlock()
+	return s.routeCost
+}
+func (s *simulation) SetRouteCost(cost api.RouteCost) {
+	s.propertyMu.Lock()
+	defer s.propertyMu.Unlock()
+	s.routeCost = cost
+}
+
+func (s *simulation) Compliance() float64 {
+	s.propertyMu.RLock()
+	defer s.propertyMu.RUnlock()
+	return s.compliance
+}
+func (s *simulation) SetCompliance(compliance float64) {
+	s.propertyMu.Lock()
+	defer s.propertyMu.Unlock()
+	s.compliance = math.Max(0, math.Min(compliance, 1))
+}
+
 func (s *simulation) Start() {
 	shouldStart := s.running.CompareAndSwap(false, true)
 	if !shouldStart {
@@ -299,12 +892,17 @@ func (s *simulation) Start() {
 	for _, r := range s.roads {
 		r.Start()
 	}
+	s.clock.start()
+	s.loop.Start()
 }
 func (s *simulation) Stop() {
 	shouldStop := s.running.CompareAndSwap(true, false)
 	if !shouldStop {
 		return
 	}
+	// the loop takes the locks on every tick, stop it before holding them
+	s.loop.Stop()
+	s.clock.stop()
 	s.citiesMu.RLock()
 	defer s.citiesMu.RUnlock()
 	s.roadsMu.RLock()
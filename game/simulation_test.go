@@ -0,0 +1,821 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"github.com/bisoncorp/graph/dijkstra"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func testSimulationData() api.SimulationData {
+	data := api.SimulationData{
+		Speed:     1,
+		Clock:     api.ClockData{Mode: api.FixedStep, Step: time.Second},
+		Seed:      42,
+		LastPlate: api.FirstPlate,
+		Cities: []api.CityData{
+			{Name: "Roma", Pos: api.Position{X: 0, Y: 0}, GenerationTime: time.Minute, ProcessingTime: time.Second},
+			{Name: "Milano", Pos: api.Position{X: 100, Y: 0}, GenerationTime: 2 * time.Minute, ProcessingTime: time.Second},
+			{Name: "Napoli", Pos: api.Position{X: 0, Y: 100}, GenerationTime: 3 * time.Minute, ProcessingTime: time.Second},
+		},
+	}
+	for _, r := range [][2]int{{0, 1}, {1, 0}, {0, 2}, {2, 0}, {1, 2}, {2, 1}} {
+		data.Roads = append(data.Roads, struct {
+			api.RoadData
+			SrcIndex, DstIndex int
+		}{RoadData: api.RoadData{MaxSpeed: 130, Lanes: 1}, SrcIndex: r[0], DstIndex: r[1]})
+	}
+	return data
+}
+
+func TestAdvanceIsReproducible(t *testing.T) {
+	run := func() api.SimulationData {
+		sim := NewFromData(testSimulationData())
+		sim.Advance(3 * time.Hour)
+		return sim.PackData()
+	}
+	first, second := run(), run()
+	if len(first.Vehicles) == 0 {
+		t.Fatal("no vehicle is travelling")
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("two runs with the same seed differ")
+	}
+	if first.Clock.Now != 3*time.Hour {
+		t.Fatalf("clock is at %v, expected %v", first.Clock.Now, 3*time.Hour)
+	}
+}
+
+func TestNewFromDataRestoresVehicles(t *testing.T) {
+	sim := NewFromData(testSimulationData())
+	sim.Advance(time.Hour)
+	saved := sim.PackData()
+	if len(saved.Vehicles) == 0 {
+		t.Fatal("no vehicle is travelling")
+	}
+	restoredSim := NewFromData(saved)
+	restored := restoredSim.PackData()
+	if !reflect.DeepEqual(saved.Vehicles, restored.Vehicles) {
+		t.Fatalf("restored vehicles differ:\n%v\n%v", saved.Vehicles, restored.Vehicles)
+	}
+	v := restoredSim.Vehicle(saved.Vehicles[0].Plate)
+	if v == nil {
+		t.Fatalf("vehicle %s not found", saved.Vehicles[0].Plate)
+	}
+	trip := v.Trip()
+	if trip.Dst().Name() != saved.Vehicles[0].Itinerary.Cities[len(saved.Vehicles[0].Itinerary.Cities)-1] {
+		t.Fatalf("restored trip %s does not reach the saved destination", trip.String())
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	sim := NewFromData(testSimulationData())
+	counts := make(map[api.EventType]int)
+	unsubscribe := sim.Subscribe(func(e api.Event) {
+		if e.Vehicle == nil {
+			t.Errorf("%v without vehicle", e.Type)
+		}
+		counts[e.Type]++
+	}, api.VehicleSpawned, api.RoadEntered, api.CityArrived, api.TripCompleted)
+	sim.Advance(3 * time.Hour)
+	for _, et := range []api.EventType{api.VehicleSpawned, api.RoadEntered, api.CityArrived, api.TripCompleted} {
+		if counts[et] == 0 {
+			t.Errorf("no %v event", et)
+		}
+	}
+
+	unsubscribe()
+	removed := 0
+	sim.Subscribe(func(e api.Event) { removed++ }, api.CityRemoved, api.RoadRemoved)
+	spawned := counts[api.VehicleSpawned]
+	sim.RemoveCity(sim.City("Napoli"))
+	sim.Advance(time.Hour)
+	if counts[api.VehicleSpawned] != spawned {
+		t.Error("events received after unsubscribe")
+	}
+	if removed != 5 {
+		t.Errorf("expected 5 remove events, got %d", removed)
+	}
+	if sim.City("Napoli") != nil {
+		t.Error("removed city is still found")
+	}
+}
+
+func TestStats(t *testing.T) {
+	sim := NewFromData(testSimulationData())
+	sim.Advance(3 * time.Hour)
+	stats := sim.Stats()
+	if stats.Period != 3*time.Hour || len(stats.Trips) == 0 || stats.Completed < len(stats.Trips) {
+		t.Fatalf("unexpected stats for %v with %d trips", stats.Period, stats.Completed)
+	}
+	for _, trip := range stats.Trips {
+		if trip.Origin == trip.Destination || trip.Hops < 1 || trip.Distance <= 0 || trip.TravelTime() <= 0 {
+			t.Fatalf("unexpected trip %+v", trip)
+		}
+	}
+}
+
+func TestRoadMetrics(t *testing.T) {
+	sim := NewFromData(testSimulationData())
+	sim.Advance(3 * time.Hour)
+	atob, _ := sim.Road("Roma", "Milano")
+	metrics := atob.Metrics()
+	if metrics.Entered == 0 || metrics.Exited == 0 || metrics.Entered-metrics.Exited != metrics.Vehicles {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	if metrics.MeanDwellTime <= 0 || metrics.AverageSpeed <= 0 || metrics.AverageSpeed > atob.MaxSpeed() {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	if history := atob.History(); len(history) != 180 || history[len(history)-1].Time != 3*time.Hour {
+		t.Fatalf("unexpected history of %d samples", len(history))
+	}
+
+	restored, _ := NewFromData(sim.PackData()).Road("Roma", "Milano")
+	if m := restored.Metrics(); m.Entered != 0 || m.Vehicles != metrics.Vehicles {
+		t.Errorf("restored road counts %d entered of %d vehicles", m.Entered, m.Vehicles)
+	}
+
+	data := testSimulationData()
+	data.Clock.Step = 10 * time.Minute
+	sim = NewFromData(data)
+	sim.Advance(time.Hour)
+	atob, _ = sim.Road("Roma", "Milano")
+	history := atob.History()
+	if len(history) != 60 {
+		t.Fatalf("%d samples in an hour of long steps", len(history))
+	}
+	for i, sample := range history {
+		if sample.Time != time.Duration(i+1)*time.Minute {
+			t.Fatalf("sample %d taken at %v", i, sample.Time)
+		}
+	}
+}
+
+func TestCityMetrics(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[1].ProcessingTime = 10 * time.Minute
+	sim := NewFromData(data)
+	sim.Advance(3 * time.Hour)
+	m := sim.City("Milano").Metrics()
+	if m.Processed == 0 || m.QueueLength == 0 || m.MaxQueueLength < m.QueueLength || m.MeanWaitTime <= 0 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+	// one vehicle every ten minutes
+	if m.ProcessedPerHour > 6 {
+		t.Fatalf("processed %v per hour", m.ProcessedPerHour)
+	}
+}
+
+func TestCityCapacity(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[1].ProcessingTime = 10 * time.Minute
+	data.Cities[1].Capacity = 2
+	sim := NewFromData(data)
+	sim.Advance(3 * time.Hour)
+	if m := sim.City("Milano").Metrics(); m.MaxQueueLength > 2 {
+		t.Fatalf("queue reached %d vehicles", m.MaxQueueLength)
+	}
+	atob, _ := sim.Road("Roma", "Milano")
+	waiting := 0
+	for _, v := range atob.Vehicles() {
+		if v.Progress() == 1 {
+			waiting++
+		}
+	}
+	if waiting == 0 {
+		t.Fatal("no vehicle is waiting at the end of the road")
+	}
+}
+
+func TestQueuedRoundTrip(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[1].ProcessingTime = 10 * time.Minute
+	data.Cities[1].Capacity = 2
+	sim := NewFromData(data)
+	sim.Advance(3 * time.Hour)
+	packed := sim.PackData()
+	if len(packed.Queued) == 0 || len(packed.Queued) > 2 || packed.Queued[0].CityIndex != 1 {
+		t.Fatalf("queued vehicles packed as %+v", packed.Queued)
+	}
+	if err := packed.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewFromData(packed)
+	if m := restored.City("Milano").Metrics(); m.QueueLength != len(packed.Queued) {
+		t.Errorf("restored queue has %d vehicles", m.QueueLength)
+	}
+	for _, vd := range packed.Queued {
+		if restored.Vehicle(vd.Plate) == nil {
+			t.Errorf("queued vehicle %s is not found", vd.Plate)
+		}
+	}
+	if repacked := restored.PackData(); !reflect.DeepEqual(repacked.Queued, packed.Queued) {
+		t.Errorf("queued vehicles repacked as %+v", repacked.Queued)
+	}
+}
+
+func TestFullCityRoundTrip(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[1].ProcessingTime = 10 * time.Minute
+	data.Cities[1].Capacity = 2
+	sim := NewFromData(data)
+	held := func(sim api.Simulation) []string {
+		atob, _ := sim.Road("Roma", "Milano")
+		plates := make([]string, 0)
+		for _, v := range atob.Vehicles() {
+			if v.Progress() == 1 {
+				plates = append(plates, v.Plate())
+			}
+		}
+		sort.Strings(plates)
+		return plates
+	}
+	for sim.City("Milano").Metrics().QueueLength < 2 || len(held(sim)) == 0 {
+		sim.Advance(time.Minute)
+		if sim.Clock().Now() > 6*time.Hour {
+			t.Fatal("Milano never gets full")
+		}
+	}
+
+	restored := NewFromData(sim.PackData())
+	if m := restored.City("Milano").Metrics(); m.QueueLength != 2 {
+		t.Errorf("restored full city has %d queued vehicles", m.QueueLength)
+	}
+	plates := held(sim)
+	if restoredPlates := held(restored); !reflect.DeepEqual(restoredPlates, plates) {
+		t.Fatalf("held vehicles %v restored as %v", plates, restoredPlates)
+	}
+	entered := make(map[string]bool)
+	restored.Subscribe(func(e api.Event) {
+		if e.City.Name() == "Milano" {
+			entered[e.Vehicle.Plate()] = true
+		}
+	}, api.CityArrived)
+	restored.Advance(time.Hour)
+	for _, plate := range plates {
+		if !entered[plate] {
+			t.Errorf("held vehicle %s never entered Milano", plate)
+		}
+	}
+}
+
+func TestCarFollowing(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[0].GenerationTime = 5 * time.Second
+	data.Roads[0].Lanes = 3
+	sim := NewFromData(data).(*simulation)
+	for i := 0; i < 30; i++ {
+		sim.Advance(time.Minute)
+		checkHeadway(t, sim)
+	}
+}
+
+// checkHeadway fails when a vehicle is faster than allowed or closer than idmMinGap to the leader in its lane
+func checkHeadway(t *testing.T, sim *simulation) {
+	t.Helper()
+	for _, r := range sim.roads {
+		distance := api.Distance(r.src.Position(), r.dst.Position())
+		vehicles := r.Vehicles()
+		sort.SliceStable(vehicles, func(i, j int) bool { return vehicles[i].Progress() > vehicles[j].Progress() })
+		leaders := make(map[int]api.Vehicle)
+		for _, follower := range vehicles {
+			if follower.CurrentSpeed() > follower.PreferredSpeed() || follower.CurrentSpeed() > r.MaxSpeed() {
+				t.Fatalf("%s drives at %f", follower.Plate(), follower.CurrentSpeed())
+			}
+			leader, exist := leaders[follower.Lane()]
+			leaders[follower.Lane()] = follower
+			if !exist {
+				continue
+			}
+			if gap := (leader.Progress()-follower.Progress())*distance - leader.Type().Class().Length; gap < idmMinGap-1e-9 {
+				t.Fatalf("%s follows %s at %f km", follower.Plate(), leader.Plate(), gap)
+			}
+		}
+	}
+}
+
+func TestOvertaking(t *testing.T) {
+	overtaken := func(lanes int) bool {
+		data := testSimulationData()
+		for i := range data.Cities {
+			data.Cities[i].GenerationTime = 24 * time.Hour
+		}
+		data.Roads[0].Lanes = lanes
+		for _, vd := range []api.VehicleData{
+			{Plate: "slow", PreferredSpeed: 60, CurrentSpeed: 60, Progress: 0.1},
+			{Plate: "fast", PreferredSpeed: 130, CurrentSpeed: 60, Progress: 0.09},
+		} {
+			data.Vehicles = append(data.Vehicles, struct {
+				api.VehicleData
+				RoadIndex int
+			}{VehicleData: vd, RoadIndex: 0})
+		}
+		sim := NewFromData(data)
+		sim.Advance(10 * time.Minute)
+		return sim.Vehicle("fast").Progress() > sim.Vehicle("slow").Progress()
+	}
+	if overtaken(1) {
+		t.Fatal("overtaken on a single lane road")
+	}
+	if !overtaken(2) {
+		t.Fatal("not overtaken on a two lanes road")
+	}
+}
+
+func TestOvertakingWithLongSteps(t *testing.T) {
+	run := func(step time.Duration) map[string]api.VehicleData {
+		data := testSimulationData()
+		data.Clock.Step = step
+		for i := range data.Cities {
+			data.Cities[i].GenerationTime = 24 * time.Hour
+		}
+		data.Roads[0].Lanes = 2
+		for _, vd := range []api.VehicleData{
+			{Plate: "slow", PreferredSpeed: 60, CurrentSpeed: 60, Progress: 0.1},
+			{Plate: "fast", PreferredSpeed: 130, CurrentSpeed: 60, Progress: 0.09},
+			{Plate: "faster", PreferredSpeed: 200, CurrentSpeed: 60, Progress: 0.08},
+		} {
+			data.Vehicles = append(data.Vehicles, struct {
+				api.VehicleData
+				RoadIndex int
+			}{VehicleData: vd, RoadIndex: 0})
+		}
+		sim := NewFromData(data).(*simulation)
+		for elapsed := time.Duration(0); elapsed < 5*time.Minute; elapsed += step {
+			sim.Advance(step)
+			checkHeadway(t, sim)
+		}
+		vehicles := make(map[string]api.VehicleData)
+		for _, vd := range sim.PackData().Vehicles {
+			vehicles[vd.Plate] = vd.VehicleData
+		}
+		return vehicles
+	}
+	// a tick of 10s is integrated in substeps of 1s, the vehicles move as with ticks of 1s
+	short, long := run(time.Second), run(10*time.Second)
+	for plate, vd := range short {
+		if math.Abs(long[plate].Progress-vd.Progress) > 1e-9 || long[plate].Lane != vd.Lane {
+			t.Errorf("%s is at %f in lane %d instead of %f in lane %d", plate, long[plate].Progress, long[plate].Lane, vd.Progress, vd.Lane)
+		}
+	}
+	if long["fast"].Progress <= long["slow"].Progress {
+		t.Fatal("not overtaken on a two lanes road")
+	}
+}
+
+func TestRoadCapacity(t *testing.T) {
+	speeds := func(capacity float64) (flow, average float64) {
+		data := testSimulationData()
+		data.Cities[0].GenerationTime = 5 * time.Second
+		data.Roads[0].Capacity = capacity
+		sim := NewFromData(data)
+		sim.Advance(30 * time.Minute)
+		r, _ := sim.Road("Roma", "Milano")
+		return r.Metrics().FlowSpeed, r.Metrics().AverageSpeed
+	}
+	flow, free := speeds(0)
+	if flow != 130 {
+		t.Fatalf("flow speed %f on a road with unlimited capacity", flow)
+	}
+	flow, congested := speeds(400)
+	if flow >= 130 || congested >= free {
+		t.Fatalf("flow speed %f and average speed %f, free average speed %f", flow, congested, free)
+	}
+}
+
+func TestRouteCost(t *testing.T) {
+	data := testSimulationData()
+	// the direct road from Roma to Milano is shorter but slower than the one through Napoli
+	data.Roads[0].MaxSpeed = 30
+	sim := NewFromData(data).(*simulation)
+	for cost, hops := range map[api.RouteCost]int{api.RoadLength: 2, api.FreeFlowTime: 3, api.CongestedTime: 3} {
+		sim.SetRouteCost(cost)
+		if path := dijkstra.ShortestPath(sim, 0, 1); len(path) != hops {
+			t.Errorf("%v route is %v", cost, path)
+		}
+	}
+	for _, r := range sim.roads {
+		if w := r.Weight(); w <= 0 {
+			t.Errorf("empty road weights %d", w)
+		}
+	}
+
+	// a path through every city on closed roads does not overflow
+	sum := 0
+	for _, r := range sim.roads[:len(sim.cities)-1] {
+		sim.AddIncident(api.Incident{Src: r.src.Name(), Dst: r.dst.Name(), End: time.Hour})
+		if sum += r.Weight(); sum <= 0 {
+			t.Fatalf("path weight overflows to %d", sum)
+		}
+	}
+}
+
+func TestRerouting(t *testing.T) {
+	data := testSimulationData()
+	data.RouteCost = api.FreeFlowTime
+	// from Roma, Milano is reached faster through Napoli than on the direct road
+	data.Roads[0].MaxSpeed = 30
+	sim := NewFromData(data).(*simulation)
+	roma, napoli, milano := sim.cities[0], sim.cities[2], sim.cities[1]
+	for _, reroutes := range []bool{false, true} {
+		v := newVehicle(api.VehicleData{Plate: "test", PreferredSpeed: 130, Reroutes: reroutes},
+			api.NewTripAt([]api.City{napoli, roma, milano}, 1))
+		roma.route(v)
+		trip := v.Trip()
+		expected := api.City(milano)
+		if reroutes {
+			expected = napoli
+		}
+		if trip.Current() != expected {
+			t.Errorf("rerouting %v: travelling toward %s on %s", reroutes, trip.Current().Name(), trip.String())
+		}
+	}
+
+	data.Compliance = 0.5
+	sim = NewFromData(data).(*simulation)
+	sim.Advance(time.Hour)
+	count := map[bool]int{}
+	for _, vd := range sim.PackData().Vehicles {
+		count[vd.Reroutes]++
+	}
+	if count[true] == 0 || count[false] == 0 {
+		t.Fatalf("rerouting vehicles %d, others %d", count[true], count[false])
+	}
+}
+
+func TestUnreachableDestinations(t *testing.T) {
+	data := testSimulationData()
+	data.Cities, data.Roads = data.Cities[:1], nil
+	sim := NewFromData(data)
+	sim.Advance(time.Hour)
+	if n := sim.PackData().LastPlate.Ordinal(); n != 0 {
+		t.Fatalf("%d vehicles generated without destinations", n)
+	}
+
+	// without the roads between Milano and Napoli, the vehicles travel through Roma
+	data = testSimulationData()
+	data.Roads = data.Roads[:4]
+	sim = NewFromData(data)
+	sim.Advance(time.Hour)
+	romaToNapoli, _ := sim.Road("Roma", "Napoli")
+	sim.RemoveRoad(romaToNapoli)
+	sim.Advance(3 * time.Hour)
+	if sim.City("Roma").Metrics().Stranded == 0 {
+		t.Fatal("no vehicle directed to the unreachable city is stranded")
+	}
+}
+
+func TestRemovedRoadStrandsVehicles(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[0].GenerationTime = 10 * time.Second
+	sim := NewFromData(data)
+	sim.Advance(10 * time.Minute)
+	stranded := 0
+	sim.Subscribe(func(e api.Event) {
+		if e.City.Name() == "Roma" {
+			stranded++
+		}
+	}, api.VehicleStranded)
+	atob, _ := sim.Road("Roma", "Milano")
+	vehicles := len(atob.Vehicles())
+	if vehicles == 0 {
+		t.Fatal("no vehicle on the road")
+	}
+	before := sim.City("Roma").Metrics().Stranded
+	sim.RemoveRoad(atob)
+	if stranded != vehicles || sim.City("Roma").Metrics().Stranded-before != vehicles {
+		t.Fatalf("%d vehicles on the removed road, %d stranded", vehicles, stranded)
+	}
+}
+
+func TestDemand(t *testing.T) {
+	data := testSimulationData()
+	data.Demand = []api.Demand{{ODPair: api.ODPair{Origin: "Roma", Destination: "Napoli"}, Weight: 1}}
+	sim := NewFromData(data)
+	sim.Advance(3 * time.Hour)
+	fromRoma := 0
+	for _, trip := range sim.Stats().Trips {
+		if trip.Origin != "Roma" {
+			continue
+		}
+		fromRoma++
+		if trip.Destination != "Napoli" {
+			t.Fatalf("trip from Roma to %s", trip.Destination)
+		}
+	}
+	if fromRoma == 0 {
+		t.Fatal("no trip from Roma")
+	}
+
+	sim.RemoveCity(sim.City("Napoli"))
+	if demand := sim.Demand(); len(demand) != 0 {
+		t.Fatalf("demand %v toward the removed city is kept", demand)
+	}
+}
+
+func TestSetDemandWhileRemovingCities(t *testing.T) {
+	sim := NewFromData(testSimulationData())
+	demand := []api.Demand{{ODPair: api.ODPair{Origin: "Roma", Destination: "Torino"}, Weight: 1}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			sim.SetDemand(demand)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		sim.AddCity(api.CityData{Name: "Torino", GenerationTime: time.Minute, ProcessingTime: time.Second})
+		sim.RemoveCity(sim.City("Torino"))
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("SetDemand is deadlocked")
+	}
+	for _, d := range sim.Demand() {
+		if sim.City(d.Destination) == nil {
+			t.Fatalf("demand %v toward a removed city", d)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	data := testSimulationData()
+	for i := range data.Cities {
+		data.Cities[i].Profile = make([]float64, api.HoursPerDay)
+	}
+	// Roma only generates vehicles between 2 and 3, at twice the base rate
+	data.Cities[0].Profile[2] = 2
+	sim := NewFromData(data)
+	spawned := make(map[time.Duration]int)
+	sim.Subscribe(func(e api.Event) {
+		spawned[e.Time/time.Hour]++
+	}, api.VehicleSpawned)
+	sim.Advance(4 * time.Hour)
+	if len(spawned) != 1 || spawned[2] != 120 {
+		t.Fatalf("vehicles spawned by hour %v", spawned)
+	}
+
+	// ticks of 90 minutes cross the hours of the profile
+	data.Clock.Step = 90 * time.Minute
+	sim = NewFromData(data)
+	total := 0
+	sim.Subscribe(func(e api.Event) {
+		total++
+	}, api.VehicleSpawned)
+	sim.Advance(4 * time.Hour)
+	if total != 120 {
+		t.Fatalf("%d vehicles spawned with long ticks", total)
+	}
+
+	roma := sim.City("Roma")
+	for _, profile := range [][]float64{{1}, append(make([]float64, api.HoursPerDay-1), -1), append(make([]float64, api.HoursPerDay-1), math.NaN())} {
+		if roma.SetProfile(profile) {
+			t.Errorf("profile %v set", profile)
+		}
+	}
+	if profile := roma.Profile(); !reflect.DeepEqual(profile, data.Cities[0].Profile) {
+		t.Errorf("profile changed to %v", profile)
+	}
+	if !roma.SetProfile(nil) || roma.Profile() != nil {
+		t.Error("profile not cleared")
+	}
+}
+
+func TestArrival(t *testing.T) {
+	for _, arrival := range api.Arrivals {
+		data := testSimulationData()
+		data.Cities = data.Cities[:2]
+		data.Roads = data.Roads[:2]
+		data.Cities[0].Arrival = arrival
+		data.Cities[1].GenerationTime = 24 * time.Hour
+		sim := NewFromData(data)
+		intervals := make([]time.Duration, 0)
+		last := time.Duration(-1)
+		sim.Subscribe(func(e api.Event) {
+			if e.City.Name() != "Roma" {
+				return
+			}
+			if last >= 0 {
+				intervals = append(intervals, e.Time-last)
+			}
+			last = e.Time
+		}, api.VehicleSpawned)
+		sim.Advance(6 * time.Hour)
+
+		sum, distinct := time.Duration(0), make(map[time.Duration]bool)
+		for _, interval := range intervals {
+			sum += interval
+			distinct[interval] = true
+		}
+		mean := sum / time.Duration(len(intervals))
+		if mean < 50*time.Second || mean > 70*time.Second {
+			t.Errorf("%v: mean interval %v", arrival, mean)
+		}
+		if regular := len(distinct) == 1; regular != (arrival == api.FixedArrival) {
+			t.Errorf("%v: %d distinct intervals", arrival, len(distinct))
+		}
+	}
+}
+
+func TestFleet(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[0].Fleet = map[api.VehicleType]float64{api.Car: 1, api.Truck: 1}
+	// the direct road from Roma to Milano is shorter but slower than the one through Napoli
+	data.Roads[0].MaxSpeed = 30
+	data.RouteCost = api.FreeFlowTime
+	sim := NewFromData(data)
+	types := make(map[api.VehicleType]int)
+	sim.Subscribe(func(e api.Event) {
+		if e.City.Name() != "Roma" {
+			return
+		}
+		vt := e.Vehicle.Type()
+		types[vt]++
+		class := vt.Class()
+		if speed := e.Vehicle.PreferredSpeed(); speed < float64(class.MinSpeed) || speed > float64(class.MaxSpeed) {
+			t.Errorf("%v prefers %f km/h", vt, speed)
+		}
+		if vt == api.Truck && e.Vehicle.Color() != class.Color {
+			t.Errorf("truck colored %v", e.Vehicle.Color())
+		}
+		trip := e.Vehicle.Trip()
+		if trip.Dst().Name() != "Milano" {
+			return
+		}
+		// trucks take the shortest road, cars the fastest one
+		if hops := len(trip.Cities()); hops != map[api.VehicleType]int{api.Car: 3, api.Truck: 2}[vt] {
+			t.Errorf("%v travels through %d cities", vt, hops)
+		}
+	}, api.VehicleSpawned)
+	sim.Advance(time.Hour)
+	if types[api.Car] == 0 || types[api.Truck] == 0 || len(types) != 2 {
+		t.Errorf("fleet generated %v", types)
+	}
+	if fleet := sim.PackData().Cities[0].Fleet; !reflect.DeepEqual(fleet, data.Cities[0].Fleet) {
+		t.Errorf("fleet packed as %v", fleet)
+	}
+}
+
+func TestLines(t *testing.T) {
+	data := testSimulationData()
+	for i := range data.Cities {
+		data.Cities[i].GenerationTime = 24 * time.Hour
+	}
+	data.Lines = []api.LineData{{Name: "1", Type: api.Bus, Stops: []string{"Roma", "Milano", "Napoli"}, Headway: 10 * time.Minute, Dwell: 2 * time.Minute}}
+	sim := NewFromData(data)
+	if sim.AddLine(api.LineData{Name: "2", Stops: []string{"Roma", "Torino"}}) != nil {
+		t.Error("line added with an unknown stop")
+	}
+	_, napoliMilano := sim.Road("Milano", "Napoli")
+	sim.RemoveRoad(napoliMilano)
+	if sim.AddLine(api.LineData{Name: "2", Stops: []string{"Napoli", "Milano"}}) != nil {
+		t.Error("line added without a road between its stops")
+	}
+	departures, completed := 0, 0
+	arrivals := make(map[string]time.Duration)
+	sim.Subscribe(func(e api.Event) {
+		if e.Vehicle.Line() != "1" {
+			return
+		}
+		switch e.Type {
+		case api.VehicleSpawned:
+			if e.City.Name() != "Roma" || e.Time%(10*time.Minute) != 0 {
+				t.Errorf("%s departed from %s at %v", e.Vehicle.Plate(), e.City.Name(), e.Time)
+			}
+			departures++
+		case api.CityArrived:
+			arrivals[e.Vehicle.Plate()] = e.Time
+		case api.RoadEntered:
+			if arrived, exist := arrivals[e.Vehicle.Plate()]; exist && e.Time-arrived < 2*time.Minute {
+				t.Errorf("%s left %s after %v", e.Vehicle.Plate(), e.City.Name(), e.Time-arrived)
+			}
+		case api.TripCompleted:
+			if trip := e.Vehicle.Trip(); len(trip.Cities()) != 3 || e.City.Name() != "Napoli" {
+				t.Errorf("%s completed in %s", e.Vehicle.Plate(), e.City.Name())
+			}
+			completed++
+		}
+	}, api.VehicleSpawned, api.CityArrived, api.RoadEntered, api.TripCompleted)
+	dwelling := 0
+	var dwellingData api.SimulationData
+	for i := 0; i < 180; i++ {
+		sim.Advance(time.Minute)
+		m := sim.City("Milano").Metrics()
+		if dwelling == 0 && m.Dwelling > 0 {
+			dwellingData = sim.PackData()
+		}
+		dwelling += m.Dwelling
+	}
+	if dwelling == 0 {
+		t.Fatal("no vehicle counted dwelling in Milano")
+	}
+
+	if err := dwellingData.Validate(); err != nil || len(dwellingData.Dwelling) == 0 {
+		t.Fatalf("%d dwelling vehicles packed, %v", len(dwellingData.Dwelling), err)
+	}
+	restored := NewFromData(dwellingData)
+	if m := restored.City("Milano").Metrics(); m.Dwelling != len(dwellingData.Dwelling) {
+		t.Errorf("%d vehicles dwelling after reload", m.Dwelling)
+	}
+	if repacked := restored.PackData(); !reflect.DeepEqual(repacked.Dwelling, dwellingData.Dwelling) {
+		t.Errorf("dwelling vehicles repacked as %+v", repacked.Dwelling)
+	}
+	bus := dwellingData.Dwelling[0]
+	left := false
+	restored.Subscribe(func(e api.Event) {
+		if e.Vehicle.Plate() == bus.Plate {
+			left = true
+			if until := dwellingData.Clock.Now + bus.Remaining; e.Time < until-time.Second || e.Time > until {
+				t.Errorf("%s left %s at %v", bus.Plate, e.City.Name(), e.Time)
+			}
+		}
+	}, api.RoadEntered)
+	restored.Advance(bus.Remaining + time.Second)
+	if !left {
+		t.Errorf("%s never left Milano", bus.Plate)
+	}
+	if departures != 18 || completed == 0 {
+		t.Errorf("%d departures, %d completed trips", departures, completed)
+	}
+	if lines := sim.PackData().Lines; !reflect.DeepEqual(lines, data.Lines) {
+		t.Errorf("lines packed as %v", lines)
+	}
+	sim.RemoveCity(sim.City("Milano"))
+	if len(sim.Lines()) != 0 {
+		t.Error("line kept without a stop")
+	}
+}
+
+func TestIncidents(t *testing.T) {
+	data := testSimulationData()
+	data.Cities[0].GenerationTime = 20 * time.Second
+	data.Incidents = []api.Incident{{Src: "Roma", Dst: "Milano", Start: 30 * time.Minute, End: time.Hour}}
+	sim := NewFromData(data)
+	if sim.AddIncident(api.Incident{Src: "Roma", Dst: "Torino", End: time.Hour}) {
+		t.Error("incident added on a missing road")
+	}
+	direct := make(map[bool]int)
+	sim.Subscribe(func(e api.Event) {
+		trip := e.Vehicle.Trip()
+		if e.City.Name() != "Roma" || trip.Dst().Name() != "Milano" {
+			return
+		}
+		closed := e.Time >= 30*time.Minute && e.Time < time.Hour
+		if cities := trip.Cities(); cities[1].Name() == "Milano" {
+			direct[closed]++
+		}
+	}, api.VehicleSpawned)
+
+	atob, _ := sim.Road("Roma", "Milano")
+	sim.Advance(35 * time.Minute)
+	if !atob.Closed() {
+		t.Fatal("road is open during the incident")
+	}
+	for _, v := range atob.Vehicles() {
+		if v.CurrentSpeed() > 0 {
+			t.Errorf("%s drives at %f on a closed road", v.Plate(), v.CurrentSpeed())
+		}
+	}
+	if incidents := sim.PackData().Incidents; !reflect.DeepEqual(incidents, data.Incidents) {
+		t.Errorf("incidents packed as %v", incidents)
+	}
+	exited := atob.Metrics().Exited
+	sim.Advance(25 * time.Minute)
+	if m := atob.Metrics(); m.Exited != exited || m.Vehicles == 0 {
+		t.Errorf("%d vehicles exited the closed road, %d are on it", m.Exited-exited, m.Vehicles)
+	}
+	sim.Advance(30 * time.Minute)
+	if atob.Closed() || atob.Metrics().Exited == exited {
+		t.Error("road is still closed after the incident")
+	}
+	if direct[true] != 0 || direct[false] == 0 {
+		t.Errorf("direct trips to Milano: %d during the incident, %d outside", direct[true], direct[false])
+	}
+	if incidents := sim.PackData().Incidents; len(incidents) != 0 {
+		t.Errorf("cleared incidents packed as %v", incidents)
+	}
+}
+
+func TestClosedOnlyRoute(t *testing.T) {
+	data := testSimulationData()
+	// Napoli is only reached through Milano
+	data.Roads = append(data.Roads[:2], data.Roads[4:]...)
+	data.Cities[0].GenerationTime = 20 * time.Second
+	data.Demand = []api.Demand{{ODPair: api.ODPair{Origin: "Roma", Destination: "Napoli"}, Weight: 1}}
+	data.Incidents = []api.Incident{{Src: "Milano", Dst: "Napoli", Start: 30 * time.Minute, End: 2 * time.Hour}}
+	sim := NewFromData(data)
+	closed, _ := sim.Road("Milano", "Napoli")
+	sim.Subscribe(func(e api.Event) {
+		if e.Road == closed && e.Time >= 30*time.Minute && e.Time < 2*time.Hour {
+			t.Errorf("%s entered the closed road at %v", e.Vehicle.Plate(), e.Time)
+		}
+	}, api.RoadEntered)
+	sim.Advance(90 * time.Minute)
+	if stranded := sim.City("Milano").Metrics().Stranded; stranded == 0 {
+		t.Error("no vehicle stranded before the closed road")
+	}
+}
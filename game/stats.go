@@ -0,0 +1,73 @@
+package game
+
+import (
+	"github.com/bisoncorp/autostrade/game/utils"
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"sync"
+	"time"
+)
+
+// statsWindow is the number of recent trips kept as the sample of the percentiles
+const statsWindow = 1000
+
+type statsRecorder struct {
+	// start is the simulated time when recording began
+	start time.Duration
+	// totals and pairs aggregate every trip, trips keeps the recent ones
+	totals  api.TripTotals
+	pairs   map[api.ODPair]api.TripTotals
+	trips   *utils.Ring[api.TripRecord]
+	tripsMu sync.RWMutex
+}
+
+func newStatsRecorder(start time.Duration, window int) *statsRecorder {
+	return &statsRecorder{start: start, pairs: make(map[api.ODPair]api.TripTotals), trips: utils.NewRing[api.TripRecord](window)}
+}
+
+// recordTrip stores the trip of v, completed at the simulated time end
+func (s *statsRecorder) recordTrip(v *vehicle, end time.Duration) {
+	v.propertyMu.RLock()
+	start, plate, trip := v.VehicleData.Departure, v.VehicleData.Plate, v.trip
+	v.propertyMu.RUnlock()
+
+	cities := trip.Cities()
+	if len(cities) == 0 {
+		return
+	}
+	distance := 0.0
+	for i := 1; i < len(cities); i++ {
+		distance += api.Distance(cities[i-1].Position(), cities[i].Position())
+	}
+	s.record(api.TripRecord{
+		Plate:       plate,
+		Origin:      cities[0].Name(),
+		Destination: cities[len(cities)-1].Name(),
+		Start:       start,
+		End:         end,
+		Distance:    distance,
+		Hops:        len(cities) - 1,
+	})
+}
+
+func (s *statsRecorder) record(record api.TripRecord) {
+	s.tripsMu.Lock()
+	defer s.tripsMu.Unlock()
+	s.totals.Completed++
+	s.totals.TravelTime += record.TravelTime()
+	pair := api.ODPair{Origin: record.Origin, Destination: record.Destination}
+	totals := s.pairs[pair]
+	totals.Completed++
+	totals.TravelTime += record.TravelTime()
+	s.pairs[pair] = totals
+	s.trips.Push(record)
+}
+
+func (s *statsRecorder) snapshot(now time.Duration) api.Stats {
+	s.tripsMu.RLock()
+	defer s.tripsMu.RUnlock()
+	pairs := make(map[api.ODPair]api.TripTotals, len(s.pairs))
+	for pair, totals := range s.pairs {
+		pairs[pair] = totals
+	}
+	return api.Stats{Period: now - s.start, TripTotals: s.totals, Pairs: pairs, Trips: s.trips.Slice()}
+}
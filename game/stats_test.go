@@ -0,0 +1,27 @@
+package game
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"testing"
+	"time"
+)
+
+func TestStatsRecorderWindow(t *testing.T) {
+	s := newStatsRecorder(0, 3)
+	for i := 1; i <= 5; i++ {
+		s.record(api.TripRecord{Origin: "Roma", Destination: "Milano", End: time.Duration(i) * time.Minute})
+	}
+	stats := s.snapshot(time.Hour)
+	if stats.Completed != 5 || stats.MeanTravelTime() != 3*time.Minute {
+		t.Errorf("%d trips completed in %v on average", stats.Completed, stats.MeanTravelTime())
+	}
+	if len(stats.Trips) != 3 || stats.Trips[0].End != 3*time.Minute {
+		t.Errorf("recent trips are %v", stats.Trips)
+	}
+	if p := stats.TravelTimePercentile(0); p != 3*time.Minute {
+		t.Errorf("min recent travel time is %v", p)
+	}
+	if rm := stats.ByODPair()[api.ODPair{Origin: "Roma", Destination: "Milano"}]; rm.Completed != 5 || len(rm.Trips) != 3 {
+		t.Errorf("unexpected Roma-Milano stats %+v", rm)
+	}
+}
@@ -48,3 +48,24 @@ func (b *baseRunnable) Stop() {
 func (b *baseRunnable) Running() bool {
 	return b.running.Load()
 }
+
+// flagRunnable has no goroutine of its own, it only records if an externally driven Updater should be updated
+type flagRunnable struct {
+	running atomic.Bool
+}
+
+func NewFlagRunnable() gameapi.Runnable {
+	return &flagRunnable{}
+}
+
+func (f *flagRunnable) Start() {
+	f.running.Store(true)
+}
+
+func (f *flagRunnable) Stop() {
+	f.running.Store(false)
+}
+
+func (f *flagRunnable) Running() bool {
+	return f.running.Load()
+}
@@ -0,0 +1,43 @@
+package utils
+
+// Ring keeps the last Cap() pushed elements, it is not safe for concurrent use
+type Ring[T any] struct {
+	buf   []T
+	start int
+	size  int
+}
+
+func NewRing[T any](capacity int) *Ring[T] {
+	return &Ring[T]{buf: make([]T, capacity)}
+}
+
+// Push appends e, overwriting the oldest element when the ring is full
+func (r *Ring[T]) Push(e T) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if r.size < len(r.buf) {
+		r.buf[(r.start+r.size)%len(r.buf)] = e
+		r.size++
+		return
+	}
+	r.buf[r.start] = e
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// Slice returns a copy of the elements, oldest first
+func (r *Ring[T]) Slice() []T {
+	s := make([]T, r.size)
+	for i := range s {
+		s[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return s
+}
+
+func (r *Ring[T]) Len() int {
+	return r.size
+}
+
+func (r *Ring[T]) Cap() int {
+	return len(r.buf)
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing[int](3)
+	r.Push(1)
+	r.Push(2)
+	if s := r.Slice(); !reflect.DeepEqual(s, []int{1, 2}) {
+		t.Fatalf("unexpected %v", s)
+	}
+	r.Push(3)
+	r.Push(4)
+	r.Push(5)
+	if s := r.Slice(); !reflect.DeepEqual(s, []int{3, 4, 5}) {
+		t.Fatalf("unexpected %v", s)
+	}
+}
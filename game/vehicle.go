@@ -4,6 +4,7 @@ import (
 	api "github.com/bisoncorp/autostrade/gameapi"
 	"image/color"
 	"sync"
+	"time"
 )
 
 type vehicle struct {
@@ -11,6 +12,8 @@ type vehicle struct {
 	propertyMu sync.RWMutex
 
 	trip api.Trip
+	// roadEntered and queueEntered are the simulated times the vehicle entered its current road and city queue
+	roadEntered, queueEntered time.Duration
 }
 
 func newVehicle(data api.VehicleData, trip api.Trip) *vehicle {
@@ -22,6 +25,11 @@ func (v *vehicle) Plate() string {
 	defer v.propertyMu.RUnlock()
 	return v.VehicleData.Plate
 }
+func (v *vehicle) Type() api.VehicleType {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	return v.VehicleData.Type
+}
 func (v *vehicle) Color() color.Color {
 	v.propertyMu.RLock()
 	defer v.propertyMu.RUnlock()
@@ -47,10 +55,59 @@ func (v *vehicle) SetPreferredSpeed(f float64) {
 	defer v.propertyMu.Unlock()
 	v.VehicleData.PreferredSpeed = f
 }
+func (v *vehicle) CurrentSpeed() float64 {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	return v.VehicleData.CurrentSpeed
+}
+func (v *vehicle) Lane() int {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	return v.VehicleData.Lane
+}
+func (v *vehicle) Reroutes() bool {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	return v.VehicleData.Reroutes
+}
+func (v *vehicle) Line() string {
+	return v.VehicleData.Line
+}
 func (v *vehicle) Trip() api.Trip {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
 	return v.trip
 }
 
+// nextCity moves the trip forward and returns the city to travel toward, arrived is set when the trip is over
+func (v *vehicle) nextCity() (next api.City, arrived bool) {
+	v.propertyMu.Lock()
+	defer v.propertyMu.Unlock()
+	if !v.trip.Arrived() {
+		v.trip.Next()
+	}
+	if v.trip.Arrived() {
+		return nil, true
+	}
+	return v.trip.Current(), false
+}
+
+// replan replaces the rest of the trip, path starts from the last city reached
+func (v *vehicle) replan(path []api.City) {
+	v.propertyMu.Lock()
+	defer v.propertyMu.Unlock()
+	v.trip.Replan(path)
+}
+
+// packData returns the vehicle data with the current itinerary
+func (v *vehicle) packData() api.VehicleData {
+	v.propertyMu.RLock()
+	defer v.propertyMu.RUnlock()
+	vd := v.VehicleData
+	vd.Itinerary = v.trip.Data()
+	return vd
+}
+
 func colorToRgba(c color.Color) color.RGBA {
 	r, g, b, a := c.RGBA()
 	return color.RGBA{
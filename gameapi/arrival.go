@@ -0,0 +1,44 @@
+package gameapi
+
+import "fmt"
+
+// Arrival is the distribution of the time between two vehicles generated by a city, GenerationTime is its mean
+type Arrival int
+
+const (
+	// FixedArrival generates a vehicle every GenerationTime
+	FixedArrival Arrival = iota
+	// ExponentialArrival generates vehicles as a Poisson process
+	ExponentialArrival
+	// UniformArrival draws the time between vehicles in [0, 2·GenerationTime]
+	UniformArrival
+	// NormalArrival draws the time between vehicles with a standard deviation of a quarter of GenerationTime
+	NormalArrival
+)
+
+// Arrivals lists every Arrival
+var Arrivals = []Arrival{FixedArrival, ExponentialArrival, UniformArrival, NormalArrival}
+
+func (a Arrival) String() string {
+	switch a {
+	case FixedArrival:
+		return "fixed"
+	case ExponentialArrival:
+		return "exponential"
+	case UniformArrival:
+		return "uniform"
+	case NormalArrival:
+		return "normal"
+	}
+	return fmt.Sprintf("Arrival(%d)", int(a))
+}
+
+// ParseArrival is the inverse of Arrival.String
+func ParseArrival(s string) (Arrival, error) {
+	for _, a := range Arrivals {
+		if a.String() == s {
+			return a, nil
+		}
+	}
+	return FixedArrival, fmt.Errorf("gameapi: unknown arrival distribution %q", s)
+}
@@ -10,7 +10,30 @@ type CityData struct {
 	Color          color.RGBA
 	Pos            Position
 	GenerationTime time.Duration
+	Arrival        Arrival // distribution of the time between two generated vehicles
 	ProcessingTime time.Duration
+	Capacity       int // maximum length of the entry queue, zero is unlimited
+	// Fleet is the relative share of every type among the generated vehicles, empty generates only cars
+	Fleet map[VehicleType]float64
+	// Profile multiplies the generation rate at every hour of the day, it is empty or HoursPerDay long.
+	// An empty profile generates a vehicle every GenerationTime
+	Profile []float64
+}
+
+// CityMetrics describe the entry queue of a city
+type CityMetrics struct {
+	// QueueLength is the number of vehicles waiting to be processed, MaxQueueLength the highest ever reached
+	QueueLength, MaxQueueLength int
+	// MeanWaitTime is the mean simulated time spent in the queue by the processed vehicles
+	MeanWaitTime time.Duration
+	// Processed counts the vehicles polled from the queue since the city was created
+	Processed int
+	// ProcessedPerHour is Processed per hour of simulated time
+	ProcessedPerHour float64
+	// Stranded counts the vehicles dropped in the city because no road leads to their destination
+	Stranded int
+	// Dwelling is the number of vehicles of the lines stopping in the city
+	Dwelling int
 }
 
 type City interface {
@@ -28,13 +51,37 @@ type City interface {
 	// SetGenerationTime set generation time
 	SetGenerationTime(time.Duration)
 
+	// Arrival is the distribution of the time between two generated vehicles, GenerationTime is its mean
+	Arrival() Arrival
+	// SetArrival set the arrival distribution
+	SetArrival(Arrival)
+
+	// Fleet is the relative share of every type among the generated vehicles, empty generates only cars
+	Fleet() map[VehicleType]float64
+	// SetFleet set the fleet mix
+	SetFleet(map[VehicleType]float64)
+
+	// Profile is the multiplier of the generation rate at every hour of the day, nil is constant
+	Profile() []float64
+	// SetProfile sets the profile, it reports false and keeps the current one if the profile is not valid for ValidProfile
+	SetProfile([]float64) bool
+
 	// ProcessingTime is time for the city to poll element from the queue
 	ProcessingTime() time.Duration
 	// SetProcessingTime set consume time
 	SetProcessingTime(time.Duration)
 
+	// Capacity is the maximum number of vehicles waiting in the queue, zero is unlimited.
+	// When the city is full the vehicles wait at the end of the incoming roads
+	Capacity() int
+	// SetCapacity set capacity
+	SetCapacity(int)
+
 	RoadsIn() []Road
 	RoadsOut() []Road
 
+	// Metrics returns the entry queue counters
+	Metrics() CityMetrics
+
 	Runnable
 }
@@ -0,0 +1,54 @@
+package gameapi
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultStep is the simulated time advanced by each tick of a FixedStep clock
+// when ClockData.Step is not set
+const DefaultStep = time.Second
+
+type ClockMode int
+
+const (
+	// RealTime advances the simulated time by the wall time elapsed since the last tick, scaled by the speed
+	RealTime ClockMode = iota
+	// FixedStep advances the simulated time in ticks of constant length, runs are reproducible
+	FixedStep
+)
+
+func (m ClockMode) String() string {
+	switch m {
+	case RealTime:
+		return "realtime"
+	case FixedStep:
+		return "fixed"
+	}
+	return fmt.Sprintf("ClockMode(%d)", int(m))
+}
+
+// ParseClockMode is the inverse of ClockMode.String
+func ParseClockMode(s string) (ClockMode, error) {
+	for _, m := range []ClockMode{RealTime, FixedStep} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return RealTime, fmt.Errorf("gameapi: unknown clock mode %q", s)
+}
+
+type ClockData struct {
+	Mode ClockMode
+	Step time.Duration
+	Now  time.Duration
+}
+
+type Clock interface {
+	// Now is the simulated time elapsed since the simulation began
+	Now() time.Duration
+	// Mode of the clock
+	Mode() ClockMode
+	// Step is the length of a tick, used by FixedStep mode and by Simulation.Advance
+	Step() time.Duration
+}
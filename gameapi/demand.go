@@ -0,0 +1,62 @@
+package gameapi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Demand is the relative weight of the trips from Origin to Destination.
+// The vehicles generated in a city with at least one Demand only travel toward the destinations
+// of its demands, proportionally to the weights. The other cities pick a uniformly random destination
+type Demand struct {
+	ODPair
+	Weight float64
+}
+
+// ReadDemandCSV reads an origin-destination matrix. The first row holds the destination names,
+// the first column the origin names and every other cell the weight of a pair, empty cells are zero.
+// Cells are separated by commas or semicolons
+func ReadDemandCSV(r io.Reader) ([]Demand, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(strings.NewReader(string(raw)))
+	if firstLine, _, _ := strings.Cut(string(raw), "\n"); strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("gameapi: reading demand: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	destinations := records[0][1:]
+	demand := make([]Demand, 0)
+	for _, record := range records[1:] {
+		origin := strings.TrimSpace(record[0])
+		for j, cell := range record[1:] {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			weight, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, fmt.Errorf("gameapi: demand from %q to %q: %w", origin, destinations[j], err)
+			}
+			if weight == 0 {
+				continue
+			}
+			demand = append(demand, Demand{
+				ODPair: ODPair{Origin: origin, Destination: strings.TrimSpace(destinations[j])},
+				Weight: weight,
+			})
+		}
+	}
+	return demand, nil
+}
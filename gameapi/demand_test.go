@@ -0,0 +1,29 @@
+package gameapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDemandCSV(t *testing.T) {
+	matrix := ";Roma;Milano;Napoli\n" +
+		"Roma;;3;1\n" +
+		"Milano;2;;0\n"
+	demand, err := ReadDemandCSV(strings.NewReader(matrix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Demand{
+		{ODPair: ODPair{Origin: "Roma", Destination: "Milano"}, Weight: 3},
+		{ODPair: ODPair{Origin: "Roma", Destination: "Napoli"}, Weight: 1},
+		{ODPair: ODPair{Origin: "Milano", Destination: "Roma"}, Weight: 2},
+	}
+	if !reflect.DeepEqual(demand, expected) {
+		t.Fatalf("unexpected demand %v", demand)
+	}
+
+	if _, err = ReadDemandCSV(strings.NewReader(",Roma\nMilano,many\n")); err == nil {
+		t.Fatal("malformed weight read without error")
+	}
+}
@@ -0,0 +1,59 @@
+package gameapi
+
+import (
+	"fmt"
+	"time"
+)
+
+type EventType int
+
+const (
+	// VehicleSpawned is emitted when City generates Vehicle
+	VehicleSpawned EventType = iota
+	// RoadEntered is emitted when Vehicle enters Road from City
+	RoadEntered
+	// CityArrived is emitted when Vehicle reaches the end of Road and joins the queue of City
+	CityArrived
+	// TripCompleted is emitted when Vehicle leaves the simulation in the destination City
+	TripCompleted
+	CityAdded
+	CityRemoved
+	RoadAdded
+	RoadRemoved
+	// VehicleStranded is emitted when Vehicle leaves the simulation in City because its destination cannot be reached
+	// or its road is removed
+	VehicleStranded
+)
+
+var eventTypeNames = [...]string{
+	VehicleSpawned:  "VehicleSpawned",
+	RoadEntered:     "RoadEntered",
+	CityArrived:     "CityArrived",
+	TripCompleted:   "TripCompleted",
+	CityAdded:       "CityAdded",
+	CityRemoved:     "CityRemoved",
+	RoadAdded:       "RoadAdded",
+	RoadRemoved:     "RoadRemoved",
+	VehicleStranded: "VehicleStranded",
+}
+
+func (t EventType) String() string {
+	if t >= 0 && int(t) < len(eventTypeNames) {
+		return eventTypeNames[t]
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event describes something that happened in a simulation, the fields not involved in the event are nil
+type Event struct {
+	Type EventType
+	// Time is the simulated time of the event
+	Time    time.Duration
+	Vehicle Vehicle
+	City    City
+	Road    Road
+}
+
+// EventCallback is called synchronously by the goroutine that caused the event,
+// it must return quickly and must not add or remove cities and roads
+type EventCallback func(Event)
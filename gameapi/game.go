@@ -44,6 +44,15 @@ func (p Plate) String() string {
 	return fmt.Sprintf("%c%c%03d%c%c", p.A, p.B, p.N, p.C, p.D)
 }
 
+// Ordinal is the position of the plate in the sequence starting at FirstPlate
+func (p Plate) Ordinal() int {
+	letters := 0
+	for _, r := range []rune{p.A, p.B, p.C, p.D} {
+		letters = letters*26 + int(r-'A')
+	}
+	return letters*1000 + p.N
+}
+
 func (p Plate) Next() Plate {
 	incrementRune := func(r rune) (rune, bool) {
 		r++
@@ -97,19 +106,42 @@ func Distance(p1, p2 Position) float64 {
 	return math.Sqrt(dx*dx + dy*dy)
 }
 
-func WriteSimulationData(data SimulationData, writer io.Writer) {
+// EncodeSimulationData writes data as indented json of FormatVersion
+func EncodeSimulationData(data SimulationData, writer io.Writer) error {
+	data.Version = FormatVersion
 	enc := json.NewEncoder(writer)
 	enc.SetIndent("", "\t")
-	err := enc.Encode(data)
+	return enc.Encode(data)
+}
+
+// DecodeSimulationData reads data written by EncodeSimulationData, migrating older versions,
+// and validates it, the validation problems are reported as a *ValidationError
+func DecodeSimulationData(reader io.Reader) (SimulationData, error) {
+	data := SimulationData{}
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return data, err
+	}
+	if raw, err = migrate(raw); err != nil {
+		return data, err
+	}
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return data, fmt.Errorf("gameapi: decoding simulation data: %w", err)
+	}
+	return data, data.Validate()
+}
+
+// WriteSimulationData is like EncodeSimulationData but panics on error
+func WriteSimulationData(data SimulationData, writer io.Writer) {
+	err := EncodeSimulationData(data, writer)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// ReadSimulationData is like DecodeSimulationData but panics on error
 func ReadSimulationData(reader io.Reader) SimulationData {
-	enc := json.NewDecoder(reader)
-	data := SimulationData{}
-	err := enc.Decode(&data)
+	data, err := DecodeSimulationData(reader)
 	if err != nil {
 		panic(err)
 	}
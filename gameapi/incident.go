@@ -0,0 +1,34 @@
+package gameapi
+
+import "time"
+
+// Incident reduces the capacity of the road from Src to Dst during a window of simulated time
+type Incident struct {
+	// Src and Dst are the names of the cities joined by the road
+	Src, Dst string
+	// Start and End are the simulated times the incident begins and is cleared
+	Start, End time.Duration
+	// Capacity is the fraction of the capacity left, in [0, 1), zero closes the road.
+	// The free-flow speed of a road without capacity is reduced instead
+	Capacity float64
+}
+
+// Active reports whether the incident is in effect at the simulated time now
+func (i Incident) Active(now time.Duration) bool {
+	return now >= i.Start && now < i.End
+}
+
+// CapacityLeft returns the fraction of the capacity of the road from src to dst left by the incidents
+// active at now, the lowest one when they overlap and one without incidents
+func CapacityLeft(incidents []Incident, src, dst string, now time.Duration) float64 {
+	left := 1.0
+	for _, i := range incidents {
+		if i.Src == src && i.Dst == dst && i.Active(now) && i.Capacity < left {
+			left = i.Capacity
+		}
+	}
+	if left < 0 {
+		return 0
+	}
+	return left
+}
@@ -0,0 +1,29 @@
+package gameapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapacityLeft(t *testing.T) {
+	incidents := []Incident{
+		{Src: "Roma", Dst: "Milano", Start: time.Hour, End: 3 * time.Hour, Capacity: 0.5},
+		{Src: "Roma", Dst: "Milano", Start: 2 * time.Hour, End: 4 * time.Hour},
+		{Src: "Milano", Dst: "Roma", Start: 0, End: 5 * time.Hour, Capacity: 0.2},
+	}
+	tests := []struct {
+		now  time.Duration
+		want float64
+	}{
+		{0, 1},
+		{time.Hour, 0.5},
+		{2 * time.Hour, 0},
+		{3 * time.Hour, 0},
+		{4 * time.Hour, 1},
+	}
+	for _, test := range tests {
+		if got := CapacityLeft(incidents, "Roma", "Milano", test.now); got != test.want {
+			t.Errorf("CapacityLeft at %v = %v, want %v", test.now, got, test.want)
+		}
+	}
+}
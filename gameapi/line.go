@@ -0,0 +1,74 @@
+package gameapi
+
+import (
+	"image/color"
+	"sort"
+	"time"
+)
+
+// Day is the period of the departure times of a Line
+const Day = HoursPerDay * time.Hour
+
+// LineData describes a public transport line, its vehicles leave the first stop on a timetable
+// and travel through every stop to the last one
+type LineData struct {
+	Name string
+	// Type of the vehicles of the line
+	Type  VehicleType
+	Color color.RGBA
+	// Stops are the names of the cities served in order, consecutive stops must be joined by a road
+	Stops []string
+	// Headway is the interval between two departures from midnight, zero uses Departures
+	Headway time.Duration
+	// Departures are the times of the day of the departures, used when Headway is zero
+	Departures []time.Duration
+	// Dwell is the time the vehicles stop in every intermediate stop, after the processing of the city
+	Dwell time.Duration
+}
+
+// NextDeparture returns the first departure at or after the simulated time after, ok is false when the line has no timetable
+func (l LineData) NextDeparture(after time.Duration) (next time.Duration, ok bool) {
+	if after < 0 {
+		after = 0
+	}
+	if l.Headway > 0 {
+		n := (after + l.Headway - 1) / l.Headway
+		return n * l.Headway, true
+	}
+	if len(l.Departures) == 0 {
+		return 0, false
+	}
+	departures := make([]time.Duration, len(l.Departures))
+	copy(departures, l.Departures)
+	sort.Slice(departures, func(i, j int) bool { return departures[i] < departures[j] })
+	midnight := after / Day * Day
+	if i := sort.Search(len(departures), func(i int) bool { return midnight+departures[i] >= after }); i < len(departures) {
+		return midnight + departures[i], true
+	}
+	return midnight + Day + departures[0], true
+}
+
+type Line interface {
+	// Name of the line, it is unique
+	Name() string
+	// Type of the vehicles of the line, it does not change
+	Type() VehicleType
+
+	Colorable
+
+	// Stops are the cities served in order, they do not change
+	Stops() []City
+
+	// Headway is the interval between two departures, zero uses Departures
+	Headway() time.Duration
+	SetHeadway(time.Duration)
+	// Departures are the times of the day of the departures, sorted
+	Departures() []time.Duration
+	SetDepartures([]time.Duration)
+	// Dwell is the time the vehicles stop in every intermediate stop
+	Dwell() time.Duration
+	SetDwell(time.Duration)
+
+	// NextDeparture is the simulated time of the next departure from the first stop, ok is false when none is scheduled
+	NextDeparture() (next time.Duration, ok bool)
+}
@@ -0,0 +1,31 @@
+package gameapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineNextDeparture(t *testing.T) {
+	headway := LineData{Headway: 15 * time.Minute}
+	timetable := LineData{Departures: []time.Duration{18 * time.Hour, 7 * time.Hour}}
+	tests := []struct {
+		line  LineData
+		after time.Duration
+		want  time.Duration
+		ok    bool
+	}{
+		{headway, 0, 0, true},
+		{headway, time.Minute, 15 * time.Minute, true},
+		{headway, 30 * time.Minute, 30 * time.Minute, true},
+		{timetable, 0, 7 * time.Hour, true},
+		{timetable, 7*time.Hour + 1, 18 * time.Hour, true},
+		{timetable, 20 * time.Hour, Day + 7*time.Hour, true},
+		{timetable, Day + 18*time.Hour, Day + 18*time.Hour, true},
+		{LineData{}, time.Hour, 0, false},
+	}
+	for _, test := range tests {
+		if got, ok := test.line.NextDeparture(test.after); got != test.want || ok != test.ok {
+			t.Errorf("NextDeparture(%v) of %v = %v, %v, want %v, %v", test.after, test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
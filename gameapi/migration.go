@@ -0,0 +1,134 @@
+package gameapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// FormatVersion is the version of SimulationData written by EncodeSimulationData
+const FormatVersion = 3
+
+// Migration upgrades a json document of SimulationData from its version to the next one.
+// Numbers are decoded as json.Number
+type Migration func(doc map[string]any) error
+
+// migrations maps each version to the migration upgrading it
+var migrations = map[int]Migration{
+	0: migrateV0,
+	1: migrateV1,
+	2: migrateV2,
+}
+
+// migrate upgrades the json document of SimulationData to FormatVersion
+func migrate(raw []byte) ([]byte, error) {
+	doc := make(map[string]any)
+	if err := unmarshalDocument(raw, &doc); err != nil {
+		return nil, fmt.Errorf("gameapi: decoding simulation data: %w", err)
+	}
+	version, err := documentVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > FormatVersion {
+		return nil, fmt.Errorf("gameapi: format version %d is newer than %d", version, FormatVersion)
+	}
+	if version == FormatVersion {
+		return raw, nil
+	}
+	for ; version < FormatVersion; version++ {
+		m, exist := migrations[version]
+		if !exist {
+			return nil, fmt.Errorf("gameapi: no migration from format version %d", version)
+		}
+		if err = m(doc); err != nil {
+			return nil, fmt.Errorf("gameapi: migrating from format version %d: %w", version, err)
+		}
+		doc["Version"] = json.Number(strconv.Itoa(version + 1))
+	}
+	return json.Marshal(doc)
+}
+
+func unmarshalDocument(raw []byte, doc *map[string]any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(doc)
+}
+
+func documentVersion(doc map[string]any) (int, error) {
+	v, exist := doc["Version"]
+	if !exist || v == nil {
+		return 0, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("gameapi: format version %v is not a number", v)
+	}
+	version, err := n.Int64()
+	return int(version), err
+}
+
+// migrateV0 upgrades the documents written before the simulation clock, when
+// city generation and processing times were measured in wall time: they are scaled
+// by the simulation speed to keep the same traffic in simulated time
+func migrateV0(doc map[string]any) error {
+	speed, err := numberField(doc, "Speed")
+	if err != nil {
+		return err
+	}
+	if _, exist := doc["Clock"]; !exist {
+		doc["Clock"] = map[string]any{"Mode": RealTime, "Step": DefaultStep, "Now": 0}
+	}
+	if speed <= 0 {
+		return nil
+	}
+	cities, _ := doc["Cities"].([]any)
+	for _, c := range cities {
+		city, ok := c.(map[string]any)
+		if !ok {
+			return fmt.Errorf("city %v is not an object", c)
+		}
+		for _, field := range []string{"GenerationTime", "ProcessingTime"} {
+			d, err := numberField(city, field)
+			if err != nil {
+				return err
+			}
+			city[field] = json.Number(strconv.FormatInt(int64(d*speed), 10))
+		}
+	}
+	return nil
+}
+
+// migrateV1 upgrades the documents written before multi-lane roads, every road has a single lane
+func migrateV1(doc map[string]any) error {
+	roads, _ := doc["Roads"].([]any)
+	for _, r := range roads {
+		road, ok := r.(map[string]any)
+		if !ok {
+			return fmt.Errorf("road %v is not an object", r)
+		}
+		road["Lanes"] = json.Number("1")
+	}
+	return nil
+}
+
+// migrateV2 upgrades the documents written before road capacities, route costs, demand, profiles, arrivals,
+// fleets, lines, incidents and the vehicles kept in cities. The zero values of the new fields keep the old behaviour,
+// the version only tells older readers that they would drop them
+func migrateV2(map[string]any) error {
+	return nil
+}
+
+// numberField returns the number stored in doc[field], zero if missing
+func numberField(doc map[string]any, field string) (float64, error) {
+	v, exist := doc[field]
+	if !exist || v == nil {
+		return 0, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%s %v is not a number", field, v)
+	}
+	return n.Float64()
+}
@@ -0,0 +1,115 @@
+package gameapi
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) SimulationData {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	data, err := DecodeSimulationData(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Version != FormatVersion {
+		t.Fatalf("%s: version is %d, expected %d", name, data.Version, FormatVersion)
+	}
+	return data
+}
+
+func TestMigrateV0(t *testing.T) {
+	data := readFixture(t, "v0.json")
+	if data.Clock.Mode != RealTime || data.Clock.Step != DefaultStep {
+		t.Fatalf("unexpected clock %+v", data.Clock)
+	}
+	// wall times are scaled by the speed of 60
+	if data.Cities[0].GenerationTime != 30*time.Second || data.Cities[0].ProcessingTime != 6*time.Second {
+		t.Fatalf("unexpected times of %+v", data.Cities[0])
+	}
+	if data.LastPlate.N != 12 || len(data.Roads) != 2 {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
+
+func TestReadV1(t *testing.T) {
+	data := readFixture(t, "v1.json")
+	if data.Seed != 1706493383123456789 {
+		t.Fatalf("seed %d lost precision", data.Seed)
+	}
+	if data.Clock.Now != time.Hour || len(data.Vehicles) != 1 || data.Vehicles[0].Itinerary.Index != 1 {
+		t.Fatalf("unexpected data %+v", data)
+	}
+	for i, r := range data.Roads {
+		if r.Lanes != 1 {
+			t.Fatalf("road %d has %d lanes after migration", i, r.Lanes)
+		}
+	}
+}
+
+func TestMigrateV2(t *testing.T) {
+	data := readFixture(t, "v2.json")
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if data.RouteCost != CongestedTime || data.Compliance != 0 || data.Demand != nil || data.Lines != nil || data.Incidents != nil {
+		t.Fatalf("unexpected defaults %+v", data)
+	}
+	if len(data.Roads) != 4 || data.Roads[0].Lanes != 2 || data.Roads[0].Capacity != 0 || data.Vehicles[0].Type != Car {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
+
+func TestReadV3(t *testing.T) {
+	data := readFixture(t, "v3.json")
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if data.RouteCost != FreeFlowTime || data.Compliance != 0.25 || len(data.Demand) != 2 || data.Demand[0].Weight != 3 {
+		t.Fatalf("unexpected routing %v, %v, %+v", data.RouteCost, data.Compliance, data.Demand)
+	}
+	roma := data.Cities[0]
+	if roma.Arrival != ExponentialArrival || len(roma.Profile) != HoursPerDay || roma.Profile[8] != 2 || roma.Fleet[Truck] != 0.15 {
+		t.Fatalf("unexpected city %+v", roma)
+	}
+	if len(data.Lines) != 1 || data.Lines[0].Type != Bus || len(data.Lines[0].Departures) != 2 || data.Vehicles[0].Line != "1" {
+		t.Fatalf("unexpected lines %+v", data.Lines)
+	}
+	if len(data.Incidents) != 1 || data.Incidents[0].Capacity != 0.5 || data.Incidents[0].End != 9*time.Hour {
+		t.Fatalf("unexpected incidents %+v", data.Incidents)
+	}
+	if len(data.Queued) != 1 || data.Queued[0].CityIndex != 0 || len(data.Dwelling) != 1 || data.Dwelling[0].Remaining != time.Minute {
+		t.Fatalf("unexpected vehicles in the cities %+v, %+v", data.Queued, data.Dwelling)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, name := range []string{"v1.json", "v2.json", "v3.json"} {
+		data := readFixture(t, name)
+		buf := bytes.Buffer{}
+		if err := EncodeSimulationData(data, &buf); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeSimulationData(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(data, decoded) {
+			t.Fatalf("%s: round trip changed data:\n%+v\n%+v", name, data, decoded)
+		}
+	}
+}
+
+func TestNewerVersionIsRejected(t *testing.T) {
+	buf := bytes.NewBufferString(`{"Version": 1000}`)
+	if _, err := DecodeSimulationData(buf); err == nil {
+		t.Fatal("newer version decoded without error")
+	}
+}
@@ -0,0 +1,56 @@
+package gameapi
+
+import (
+	"math"
+	"time"
+)
+
+// HoursPerDay is the length of a demand profile, one multiplier for every hour
+const HoursPerDay = 24
+
+// ProfileFactor returns the multiplier of profile at the simulated time now, the simulation starts at midnight.
+// An empty profile is 1 at every hour
+func ProfileFactor(profile []float64, now time.Duration) float64 {
+	if len(profile) != HoursPerDay {
+		return 1
+	}
+	hour := int(now/time.Hour) % HoursPerDay
+	if hour < 0 {
+		hour += HoursPerDay
+	}
+	return profile[hour]
+}
+
+// ValidProfile reports whether profile is empty or HoursPerDay finite multipliers not negative
+func ValidProfile(profile []float64) bool {
+	if len(profile) != 0 && len(profile) != HoursPerDay {
+		return false
+	}
+	for _, f := range profile {
+		if !validFactor(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func validFactor(f float64) bool {
+	return !math.IsNaN(f) && f >= 0 && !math.IsInf(f, 1)
+}
+
+// CommuterProfile returns a working day profile with a morning and an evening peak, its mean is 1
+// so that a city generates as many vehicles in a day as without profile
+func CommuterProfile() []float64 {
+	profile := []float64{
+		0.2, 0.1, 0.1, 0.1, 0.2, 0.5, 1.2, 2.4, 2.6, 1.4, 1.0, 1.0,
+		1.1, 1.1, 1.0, 1.1, 1.6, 2.4, 2.5, 1.6, 1.0, 0.7, 0.5, 0.3,
+	}
+	sum := 0.0
+	for _, f := range profile {
+		sum += f
+	}
+	for i := range profile {
+		profile[i] *= HoursPerDay / sum
+	}
+	return profile
+}
@@ -0,0 +1,44 @@
+package gameapi
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProfileFactor(t *testing.T) {
+	profile := CommuterProfile()
+	sum := 0.0
+	for _, f := range profile {
+		sum += f
+	}
+	if math.Abs(sum-HoursPerDay) > 1e-9 {
+		t.Fatalf("commuter profile mean is %f", sum/HoursPerDay)
+	}
+	if f := ProfileFactor(profile, 8*time.Hour+30*time.Minute); f != profile[8] {
+		t.Fatalf("factor at 8:30 is %f", f)
+	}
+	if f := ProfileFactor(profile, 24*time.Hour+8*time.Hour); f != profile[8] {
+		t.Fatalf("factor at 8:00 of the second day is %f", f)
+	}
+	if f := ProfileFactor(nil, time.Hour); f != 1 {
+		t.Fatalf("empty profile factor is %f", f)
+	}
+}
+
+func TestValidProfile(t *testing.T) {
+	if !ValidProfile(nil) || !ValidProfile(CommuterProfile()) || ValidProfile([]float64{1}) {
+		t.Fatal("unexpected validity of the profile lengths")
+	}
+	for _, f := range []float64{-1, math.NaN(), math.Inf(1)} {
+		profile := CommuterProfile()
+		profile[3] = f
+		if ValidProfile(profile) {
+			t.Errorf("profile with %v is valid", f)
+		}
+		data := SimulationData{Cities: []CityData{{Name: "Roma", GenerationTime: time.Minute, Profile: profile}}}
+		if data.Validate() == nil {
+			t.Errorf("profile with %v validated", f)
+		}
+	}
+}
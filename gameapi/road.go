@@ -1,7 +1,35 @@
 package gameapi
 
+import "time"
+
 type RoadData struct {
+	// MaxSpeed is the free-flow speed, vehicles slow down as the density of the road rises
 	MaxSpeed float64
+	// Capacity is the maximum flow in vehicles per hour per lane, zero is unlimited
+	Capacity float64
+	// Lanes is the number of lanes in the direction of the road, vehicles overtake on roads with more than one
+	Lanes int
+}
+
+// RoadMetrics are the traffic counters of a road
+type RoadMetrics struct {
+	// Entered and Exited count the vehicles since the road was created, the vehicles restored on it have not entered it
+	Entered, Exited int
+	// Vehicles currently on the road
+	Vehicles int
+	// AverageSpeed is the mean speed of the vehicles on the road during the last update
+	AverageSpeed float64
+	// FlowSpeed is the speed allowed by the density during the last update, MaxSpeed when the road is empty
+	FlowSpeed float64
+	// Density is the number of vehicles per unit of length
+	Density float64
+	// MeanDwellTime is the mean simulated time spent on the road by the vehicles that exited
+	MeanDwellTime time.Duration
+}
+
+type RoadSample struct {
+	Time time.Duration
+	RoadMetrics
 }
 
 type Road interface {
@@ -9,10 +37,27 @@ type Road interface {
 	MaxSpeed() float64
 	// SetMaxSpeed set maximum speed
 	SetMaxSpeed(float64)
+	// Capacity is the maximum flow in vehicles per hour per lane, zero is unlimited
+	Capacity() float64
+	// SetCapacity set the capacity
+	SetCapacity(float64)
+	// Lanes is the number of lanes, at least one
+	Lanes() int
+	// SetLanes set the number of lanes, vehicles on the removed lanes merge into the remaining ones
+	SetLanes(int)
+	// CapacityLeft is the fraction of the capacity left by the incidents in effect, one without incidents
+	CapacityLeft() float64
+	// Closed reports whether an incident closes the road, the vehicles on it stop and new trips avoid it
+	Closed() bool
 
 	Vehicles() []Vehicle
 	Src() City
 	Dst() City
 
+	// Metrics returns the current traffic counters
+	Metrics() RoadMetrics
+	// History returns the counters sampled at regular intervals of simulated time, oldest first
+	History() []RoadSample
+
 	Runnable
 }
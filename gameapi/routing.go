@@ -0,0 +1,45 @@
+package gameapi
+
+import "fmt"
+
+// RouteCost is the cost of a road minimised by the routing of new vehicles
+type RouteCost int
+
+const (
+	// CongestedTime is the time to travel the road at the speed of its current traffic
+	CongestedTime RouteCost = iota
+	// FreeFlowTime is the time to travel the empty road at MaxSpeed
+	FreeFlowTime
+	// RoadLength is the distance between the cities of the road
+	RoadLength
+
+	// InheritedCost makes a VehicleClass use the RouteCost of the simulation
+	InheritedCost RouteCost = -1
+)
+
+func (c RouteCost) String() string {
+	switch c {
+	case CongestedTime:
+		return "congested"
+	case FreeFlowTime:
+		return "freeflow"
+	case RoadLength:
+		return "distance"
+	case InheritedCost:
+		return "inherited"
+	}
+	return fmt.Sprintf("RouteCost(%d)", int(c))
+}
+
+// RouteCosts lists every RouteCost
+var RouteCosts = []RouteCost{CongestedTime, FreeFlowTime, RoadLength}
+
+// ParseRouteCost is the inverse of RouteCost.String
+func ParseRouteCost(s string) (RouteCost, error) {
+	for _, c := range RouteCosts {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return CongestedTime, fmt.Errorf("gameapi: unknown route cost %q", s)
+}
@@ -1,10 +1,18 @@
 package gameapi
 
+import "time"
+
 type SimulationData struct {
+	Version   int
 	Speed     float64
+	Clock     ClockData
+	Seed      int64 // seed of the random source, zero is replaced by a time based seed
 	LastPlate Plate
-	Cities    []CityData
-	Roads     []struct {
+	RouteCost RouteCost
+	// Compliance is the fraction of new vehicles planning their trip again in every city, zero disables rerouting
+	Compliance float64
+	Cities     []CityData
+	Roads      []struct {
 		RoadData
 		SrcIndex, DstIndex int
 	}
@@ -12,6 +20,25 @@ type SimulationData struct {
 		VehicleData
 		RoadIndex int
 	}
+	// Queued are the vehicles waiting in the entry queue of a city, in queue order.
+	// Their itinerary is travelling toward the city
+	Queued []struct {
+		VehicleData
+		CityIndex int
+	}
+	// Dwelling are the vehicles of the lines stopping in a city, they leave after Remaining of simulated time.
+	// Their itinerary is at the stop of the city
+	Dwelling []struct {
+		VehicleData
+		CityIndex int
+		Remaining time.Duration
+	}
+	// Demand is the origin-destination matrix, empty for uniformly random destinations
+	Demand []Demand
+	// Lines are the public transport lines
+	Lines []LineData
+	// Incidents are the closures and capacity reductions of the roads, active and scheduled
+	Incidents []Incident
 }
 
 type Simulation interface {
@@ -24,10 +51,54 @@ type Simulation interface {
 
 	City(name string) City
 	Road(a, b string) (atob, btoa Road)
+	// Vehicle returns the vehicle travelling on a road, waiting in the entry queue of a city or dwelling in it
 	Vehicle(plate string) Vehicle
 
+	// AddLine adds a public transport line, it returns nil if the name is taken, a stop is unknown,
+	// two consecutive stops are not joined by a road or there are less than two stops
+	AddLine(LineData) Line
+	// RemoveLine stops the departures of the line, its vehicles complete their trip without dwelling in the stops
+	RemoveLine(Line)
+	Line(name string) Line
+	// Lines returns the lines in the order they were added
+	Lines() []Line
+
 	PackData() SimulationData
 
+	// Demand returns the origin-destination matrix sorted by origin and destination
+	Demand() []Demand
+	// SetDemand replaces the origin-destination matrix, pairs with a zero weight or an unknown city are left out
+	SetDemand([]Demand)
+
+	// RouteCost is the cost minimised by the trips of new vehicles
+	RouteCost() RouteCost
+	// SetRouteCost changes the cost of the trips generated from now on
+	SetRouteCost(RouteCost)
+	// Compliance is the fraction of new vehicles rerouting in every city around the congested roads
+	Compliance() float64
+	// SetCompliance changes the fraction of rerouting vehicles generated from now on, it is in [0, 1]
+	SetCompliance(float64)
+
+	// Incidents returns the incidents not cleared yet sorted by start, the cleared ones are dropped
+	Incidents() []Incident
+	// SetIncidents replaces the incidents, the ones on missing roads or with an empty window are left out
+	SetIncidents([]Incident)
+	// AddIncident schedules an incident, ok is false when it is left out
+	AddIncident(Incident) (ok bool)
+
+	// Subscribe calls fn for every event of the given types, or of every type if none is given,
+	// until unsubscribe is called
+	Subscribe(fn EventCallback, types ...EventType) (unsubscribe func())
+
+	// Stats returns the trips completed since the simulation was created
+	Stats() Stats
+
+	// Clock is the source of simulated time
+	Clock() Clock
+	// Advance synchronously runs the simulation for d of simulated time, in ticks of Clock().Step().
+	// Every city and road is updated regardless of its own state, it does nothing while the simulation is running
+	Advance(d time.Duration)
+
 	Speedable
 	Runnable
 }
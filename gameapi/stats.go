@@ -0,0 +1,95 @@
+package gameapi
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TripRecord describes a completed trip
+type TripRecord struct {
+	Plate               string
+	Origin, Destination string
+	// Start and End are the simulated times of departure and arrival
+	Start, End time.Duration
+	// Distance is the length of the roads travelled
+	Distance float64
+	// Hops is the number of roads travelled
+	Hops int
+}
+
+func (r TripRecord) TravelTime() time.Duration {
+	return r.End - r.Start
+}
+
+type ODPair struct {
+	Origin, Destination string
+}
+
+// TripTotals aggregates the completed trips
+type TripTotals struct {
+	Completed  int
+	TravelTime time.Duration
+}
+
+// MeanTravelTime is zero when there is no trip
+func (t TripTotals) MeanTravelTime() time.Duration {
+	if t.Completed == 0 {
+		return 0
+	}
+	return t.TravelTime / time.Duration(t.Completed)
+}
+
+// Stats is a snapshot of the trips completed during Period of simulated time.
+// The totals count every trip, Trips keeps only the most recent ones as the sample of the percentiles
+type Stats struct {
+	Period time.Duration
+	TripTotals
+	// Pairs splits the totals by origin and destination
+	Pairs map[ODPair]TripTotals
+	Trips []TripRecord
+}
+
+// TravelTimePercentile returns the nearest-rank p-th percentile of the travel times of Trips, p is in [0, 100]
+func (s Stats) TravelTimePercentile(p float64) time.Duration {
+	if len(s.Trips) == 0 {
+		return 0
+	}
+	times := make([]time.Duration, len(s.Trips))
+	for i, t := range s.Trips {
+		times[i] = t.TravelTime()
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(times))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(times) {
+		rank = len(times)
+	}
+	return times[rank-1]
+}
+
+// Throughput is the number of trips completed per hour of simulated time
+func (s Stats) Throughput() float64 {
+	if s.Period <= 0 {
+		return 0
+	}
+	return float64(s.Completed) / s.Period.Hours()
+}
+
+// ByODPair splits the stats by origin and destination, every split keeps the same Period
+func (s Stats) ByODPair() map[ODPair]Stats {
+	pairs := make(map[ODPair]Stats, len(s.Pairs))
+	for pair, totals := range s.Pairs {
+		pairs[pair] = Stats{Period: s.Period, TripTotals: totals}
+	}
+	for _, t := range s.Trips {
+		pair := ODPair{Origin: t.Origin, Destination: t.Destination}
+		ps := pairs[pair]
+		ps.Period = s.Period
+		ps.Trips = append(ps.Trips, t)
+		pairs[pair] = ps
+	}
+	return pairs
+}
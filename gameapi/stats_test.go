@@ -0,0 +1,52 @@
+package gameapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	s := Stats{Period: 2 * time.Hour, Pairs: make(map[ODPair]TripTotals)}
+	for i, od := range []ODPair{{"Roma", "Milano"}, {"Roma", "Milano"}, {"Milano", "Roma"}, {"Roma", "Milano"}} {
+		trip := TripRecord{
+			Origin:      od.Origin,
+			Destination: od.Destination,
+			Start:       time.Duration(i) * time.Minute,
+			End:         time.Duration(i)*time.Minute + time.Duration(i+1)*10*time.Minute,
+		}
+		s.Trips = append(s.Trips, trip)
+		s.Completed++
+		s.TravelTime += trip.TravelTime()
+		totals := s.Pairs[od]
+		totals.Completed++
+		totals.TravelTime += trip.TravelTime()
+		s.Pairs[od] = totals
+	}
+	if mean := s.MeanTravelTime(); mean != 25*time.Minute {
+		t.Errorf("mean travel time is %v", mean)
+	}
+	if p := s.TravelTimePercentile(50); p != 20*time.Minute {
+		t.Errorf("median travel time is %v", p)
+	}
+	if p := s.TravelTimePercentile(100); p != 40*time.Minute {
+		t.Errorf("max travel time is %v", p)
+	}
+	if th := s.Throughput(); th != 2 {
+		t.Errorf("throughput is %v", th)
+	}
+	pairs := s.ByODPair()
+	if len(pairs) != 2 || len(pairs[ODPair{"Roma", "Milano"}].Trips) != 3 {
+		t.Errorf("unexpected pairs %v", pairs)
+	}
+
+	s.Trips = s.Trips[3:]
+	if p := s.TravelTimePercentile(50); p != 40*time.Minute {
+		t.Errorf("median travel time of the recent trips is %v", p)
+	}
+	if mean := s.MeanTravelTime(); mean != 25*time.Minute {
+		t.Errorf("mean travel time without the old trips is %v", mean)
+	}
+	if rm := s.ByODPair()[ODPair{"Roma", "Milano"}]; rm.Completed != 3 || rm.MeanTravelTime() != 70*time.Minute/3 {
+		t.Errorf("unexpected Roma-Milano stats %+v", rm)
+	}
+}
@@ -1,5 +1,11 @@
 package gameapi
 
+// TripData is the serializable form of a Trip, Index is the city the vehicle is travelling toward
+type TripData struct {
+	Cities []string
+	Index  int
+}
+
 type Trip struct {
 	cities []City
 	index  int
@@ -9,6 +15,38 @@ func NewTrip(cities []City) Trip {
 	return Trip{cities: cities}
 }
 
+// NewTripAt returns a trip already travelling toward cities[index]
+func NewTripAt(cities []City, index int) Trip {
+	return Trip{cities: cities, index: index}
+}
+
+// Data returns the city names of the trip with the current index
+func (t *Trip) Data() TripData {
+	names := make([]string, len(t.cities))
+	for i, city := range t.cities {
+		names[i] = city.Name()
+	}
+	return TripData{Cities: names, Index: t.index}
+}
+
+// Cities returns every city of the trip, from Src to Dst
+func (t *Trip) Cities() []City {
+	cities := make([]City, len(t.cities))
+	copy(cities, t.cities)
+	return cities
+}
+
+// Replan replaces the cities after the last one reached with path, which starts from the last city reached.
+// The cities already travelled are kept, a path of a single city ends the trip
+func (t *Trip) Replan(path []City) {
+	if t.index < 1 || len(path) < 1 || t.index > len(t.cities) {
+		return
+	}
+	cities := make([]City, 0, t.index+len(path)-1)
+	cities = append(cities, t.cities[:t.index]...)
+	t.cities = append(cities, path[1:]...)
+}
+
 func (t *Trip) Next() {
 	t.index++
 }
@@ -0,0 +1,227 @@
+package gameapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists every problem found in a SimulationData
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "gameapi: invalid simulation data:\n- " + strings.Join(e.Problems, "\n- ")
+}
+
+// Validate checks that data can be loaded by a simulation, the error is a *ValidationError
+func (data SimulationData) Validate() error {
+	problems := make([]string, 0)
+	addProblem := func(format string, a ...any) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if data.Speed < 0 {
+		addProblem("simulation speed %v is negative", data.Speed)
+	}
+	if data.Clock.Step < 0 {
+		addProblem("clock step %v is negative", data.Clock.Step)
+	}
+	if data.Clock.Now < 0 {
+		addProblem("clock time %v is negative", data.Clock.Now)
+	}
+	if data.RouteCost < CongestedTime || data.RouteCost > RoadLength {
+		addProblem("unknown route cost %d", int(data.RouteCost))
+	}
+	if data.Compliance < 0 || data.Compliance > 1 {
+		addProblem("compliance %v is out of [0, 1]", data.Compliance)
+	}
+
+	names := make(map[string]int, len(data.Cities))
+	for i, c := range data.Cities {
+		if c.Name == "" {
+			addProblem("city %d has no name", i)
+		} else if j, exist := names[c.Name]; exist {
+			addProblem("city %d has the same name of city %d: %q", i, j, c.Name)
+		} else {
+			names[c.Name] = i
+		}
+		if c.GenerationTime <= 0 {
+			addProblem("city %q generation time %v is not positive", c.Name, c.GenerationTime)
+		}
+		if c.ProcessingTime <= 0 {
+			addProblem("city %q processing time %v is not positive", c.Name, c.ProcessingTime)
+		}
+		if c.Capacity < 0 {
+			addProblem("city %q capacity %d is negative", c.Name, c.Capacity)
+		}
+		if c.Arrival < FixedArrival || c.Arrival > NormalArrival {
+			addProblem("city %q arrival distribution %d is unknown", c.Name, int(c.Arrival))
+		}
+		for vt, share := range c.Fleet {
+			if vt < Car || vt > Motorbike {
+				addProblem("city %q fleet has the unknown vehicle type %d", c.Name, int(vt))
+			}
+			if share < 0 {
+				addProblem("city %q fleet share of %v is negative", c.Name, vt)
+			}
+		}
+		if len(c.Profile) != 0 && len(c.Profile) != HoursPerDay {
+			addProblem("city %q profile has %d hours instead of %d", c.Name, len(c.Profile), HoursPerDay)
+		}
+		for hour, f := range c.Profile {
+			if !validFactor(f) {
+				addProblem("city %q profile is not a finite number or is negative at hour %d", c.Name, hour)
+			}
+		}
+	}
+
+	validCity := func(index int) bool { return index >= 0 && index < len(data.Cities) }
+	roads := make(map[[2]int]int, len(data.Roads))
+	for i, r := range data.Roads {
+		if !validCity(r.SrcIndex) {
+			addProblem("road %d source index %d is out of range", i, r.SrcIndex)
+		}
+		if !validCity(r.DstIndex) {
+			addProblem("road %d destination index %d is out of range", i, r.DstIndex)
+		}
+		if r.SrcIndex == r.DstIndex {
+			addProblem("road %d starts and ends in the same city", i)
+		}
+		key := [2]int{r.SrcIndex, r.DstIndex}
+		if j, exist := roads[key]; exist {
+			addProblem("road %d duplicates road %d", i, j)
+		} else {
+			roads[key] = i
+		}
+		if r.MaxSpeed <= 0 {
+			addProblem("road %d max speed %v is not positive", i, r.MaxSpeed)
+		}
+		if r.Capacity < 0 {
+			addProblem("road %d capacity %v is negative", i, r.Capacity)
+		}
+		if r.Lanes < 1 {
+			addProblem("road %d has %d lanes", i, r.Lanes)
+		}
+	}
+
+	for _, i := range data.Incidents {
+		src, srcExist := names[i.Src]
+		dst, dstExist := names[i.Dst]
+		if _, exist := roads[[2]int{src, dst}]; !srcExist || !dstExist || !exist {
+			addProblem("incident from %q to %q is not on a road", i.Src, i.Dst)
+		}
+		if i.Start < 0 || i.End <= i.Start {
+			addProblem("incident from %q to %q window [%v, %v) is not valid", i.Src, i.Dst, i.Start, i.End)
+		}
+		if i.Capacity < 0 || i.Capacity >= 1 {
+			addProblem("incident from %q to %q capacity %v is out of [0, 1)", i.Src, i.Dst, i.Capacity)
+		}
+	}
+
+	pairs := make(map[ODPair]bool, len(data.Demand))
+	for _, d := range data.Demand {
+		for _, name := range []string{d.Origin, d.Destination} {
+			if _, exist := names[name]; !exist {
+				addProblem("demand from %q to %q refers to the unknown city %q", d.Origin, d.Destination, name)
+			}
+		}
+		if d.Origin == d.Destination {
+			addProblem("demand from %q to itself", d.Origin)
+		}
+		if d.Weight < 0 {
+			addProblem("demand from %q to %q weight %v is negative", d.Origin, d.Destination, d.Weight)
+		}
+		if pairs[d.ODPair] {
+			addProblem("demand from %q to %q is repeated", d.Origin, d.Destination)
+		}
+		pairs[d.ODPair] = true
+	}
+
+	lines := make(map[string]bool, len(data.Lines))
+	for i, l := range data.Lines {
+		if l.Name == "" {
+			addProblem("line %d has no name", i)
+		} else if lines[l.Name] {
+			addProblem("line %q is repeated", l.Name)
+		}
+		lines[l.Name] = true
+		if l.Type < Car || l.Type > Motorbike {
+			addProblem("line %q vehicle type %d is unknown", l.Name, int(l.Type))
+		}
+		if len(l.Stops) < 2 {
+			addProblem("line %q has %d stops", l.Name, len(l.Stops))
+		}
+		for j, stop := range l.Stops {
+			if _, exist := names[stop]; !exist {
+				addProblem("line %q stops in the unknown city %q", l.Name, stop)
+			}
+			if j == 0 {
+				continue
+			}
+			if l.Stops[j-1] == stop {
+				addProblem("line %q stops twice in a row in %q", l.Name, stop)
+			} else if _, exist := roads[[2]int{names[l.Stops[j-1]], names[stop]}]; !exist {
+				addProblem("line %q has no road from %q to %q", l.Name, l.Stops[j-1], stop)
+			}
+		}
+		if l.Headway < 0 {
+			addProblem("line %q headway %v is negative", l.Name, l.Headway)
+		}
+		for _, d := range l.Departures {
+			if d < 0 || d >= Day {
+				addProblem("line %q departure %v is out of the day", l.Name, d)
+			}
+		}
+		if l.Dwell < 0 {
+			addProblem("line %q dwell time %v is negative", l.Name, l.Dwell)
+		}
+	}
+
+	for _, v := range data.Vehicles {
+		if v.RoadIndex < 0 || v.RoadIndex >= len(data.Roads) {
+			addProblem("vehicle %q road index %d is out of range", v.Plate, v.RoadIndex)
+		} else if lanes := data.Roads[v.RoadIndex].Lanes; v.Lane < 0 || v.Lane >= lanes {
+			addProblem("vehicle %q lane %d is out of the %d lanes of its road", v.Plate, v.Lane, lanes)
+		}
+		if v.Type < Car || v.Type > Motorbike {
+			addProblem("vehicle %q type %d is unknown", v.Plate, int(v.Type))
+		}
+		if v.PreferredSpeed <= 0 {
+			addProblem("vehicle %q preferred speed %v is not positive", v.Plate, v.PreferredSpeed)
+		}
+		if v.CurrentSpeed < 0 {
+			addProblem("vehicle %q current speed %v is negative", v.Plate, v.CurrentSpeed)
+		}
+		if v.Progress < 0 || v.Progress > 1 {
+			addProblem("vehicle %q progress %v is out of [0, 1]", v.Plate, v.Progress)
+		}
+	}
+	for _, v := range data.Queued {
+		if !validCity(v.CityIndex) {
+			addProblem("queued vehicle %q city index %d is out of range", v.Plate, v.CityIndex)
+		}
+		if v.Type < Car || v.Type > Motorbike {
+			addProblem("vehicle %q type %d is unknown", v.Plate, int(v.Type))
+		}
+		if v.PreferredSpeed <= 0 {
+			addProblem("vehicle %q preferred speed %v is not positive", v.Plate, v.PreferredSpeed)
+		}
+	}
+	for _, v := range data.Dwelling {
+		if !validCity(v.CityIndex) {
+			addProblem("dwelling vehicle %q city index %d is out of range", v.Plate, v.CityIndex)
+		}
+		if !lines[v.Line] {
+			addProblem("dwelling vehicle %q is not of a line", v.Plate)
+		}
+		if v.Remaining < 0 {
+			addProblem("dwelling vehicle %q remaining dwell time %v is negative", v.Plate, v.Remaining)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
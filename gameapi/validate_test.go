@@ -0,0 +1,56 @@
+package gameapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	data := SimulationData{
+		Cities: []CityData{
+			{Name: "Roma", GenerationTime: time.Minute, ProcessingTime: time.Second},
+			{Name: "Roma", GenerationTime: 0, ProcessingTime: time.Second},
+		},
+		Roads: []struct {
+			RoadData
+			SrcIndex, DstIndex int
+		}{
+			{RoadData: RoadData{MaxSpeed: 130, Lanes: 1}, SrcIndex: 0, DstIndex: 1},
+			{RoadData: RoadData{MaxSpeed: 130, Lanes: 1}, SrcIndex: 0, DstIndex: 5},
+		},
+	}
+	err := data.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if len(validationErr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %q", validationErr.Problems)
+	}
+
+	data.Cities[1].Name, data.Cities[1].GenerationTime = "Milano", time.Minute
+	data.Roads = data.Roads[:1]
+	if err = data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	data.Lines = []LineData{{Name: "1", Type: Bus, Stops: []string{"Milano", "Roma"}, Headway: time.Hour}}
+	if err = data.Validate(); !errors.As(err, &validationErr) || len(validationErr.Problems) != 1 {
+		t.Fatalf("line without a road validated as %v", err)
+	}
+	data.Lines[0].Stops = []string{"Roma", "Milano"}
+	if err = data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeSimulationData(t *testing.T) {
+	if _, err := DecodeSimulationData(strings.NewReader("{")); err == nil {
+		t.Fatal("malformed json decoded without error")
+	}
+	if _, err := DecodeSimulationData(strings.NewReader(`{"Speed": -1}`)); err == nil {
+		t.Fatal("negative speed decoded without error")
+	}
+}
@@ -1,17 +1,29 @@
 package gameapi
 
-import "image/color"
+import (
+	"image/color"
+	"time"
+)
 
 type VehicleData struct {
 	Plate          string
+	Type           VehicleType
 	Color          color.RGBA
 	Progress       float64
 	PreferredSpeed float64
+	CurrentSpeed   float64
+	Lane           int
+	Reroutes       bool   // the driver plans the rest of the trip again in every city
+	Line           string // name of the public transport line of the vehicle, empty for private vehicles
+	Itinerary      TripData
+	Departure      time.Duration // simulated time the vehicle was generated
 }
 
 type Vehicle interface {
 	// Plate is the license plate of vehicle, it is unique
 	Plate() string
+	// Type of the vehicle, it does not change
+	Type() VehicleType
 
 	Colorable
 
@@ -22,6 +34,15 @@ type Vehicle interface {
 	PreferredSpeed() float64
 	// SetPreferredSpeed set speed of the vehicle, the speed is capped to Road().MaxSpeed()
 	SetPreferredSpeed(float64)
+	// CurrentSpeed is the speed on the current road, slower than PreferredSpeed behind slower vehicles
+	CurrentSpeed() float64
+	// Lane is the lane of the current road, zero is the rightmost
+	Lane() int
+
+	// Reroutes reports whether the driver plans the rest of the trip again in every city, using the current road costs
+	Reroutes() bool
+	// Line is the name of the public transport line of the vehicle, empty for private vehicles
+	Line() string
 
 	Trip() Trip
 }
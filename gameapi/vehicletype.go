@@ -0,0 +1,70 @@
+package gameapi
+
+import (
+	"fmt"
+	"image/color"
+)
+
+type VehicleType int
+
+const (
+	Car VehicleType = iota
+	Truck
+	Bus
+	Motorbike
+)
+
+// VehicleTypes lists every VehicleType
+var VehicleTypes = []VehicleType{Car, Truck, Bus, Motorbike}
+
+func (t VehicleType) String() string {
+	switch t {
+	case Car:
+		return "car"
+	case Truck:
+		return "truck"
+	case Bus:
+		return "bus"
+	case Motorbike:
+		return "motorbike"
+	}
+	return fmt.Sprintf("VehicleType(%d)", int(t))
+}
+
+// ParseVehicleType is the inverse of VehicleType.String
+func ParseVehicleType(s string) (VehicleType, error) {
+	for _, t := range VehicleTypes {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return Car, fmt.Errorf("gameapi: unknown vehicle type %q", s)
+}
+
+// VehicleClass describes the vehicles of a type
+type VehicleClass struct {
+	// MinSpeed and MaxSpeed bound the uniformly distributed preferred speed, in km/h, both included
+	MinSpeed, MaxSpeed int
+	// Length in km
+	Length float64
+	// Color of the vehicles, a transparent color takes the color of the city generating the vehicle
+	Color color.RGBA
+	// RouteCost minimised by the trips of the vehicles, InheritedCost uses the one of the simulation
+	RouteCost RouteCost
+}
+
+// vehicleClasses describes every VehicleType, it is read only
+var vehicleClasses = map[VehicleType]VehicleClass{
+	Car:       {MinSpeed: 80, MaxSpeed: 579, Length: 0.005, RouteCost: InheritedCost},
+	Truck:     {MinSpeed: 60, MaxSpeed: 90, Length: 0.016, Color: color.RGBA{R: 120, G: 80, B: 40, A: 255}, RouteCost: RoadLength},
+	Bus:       {MinSpeed: 70, MaxSpeed: 100, Length: 0.012, Color: color.RGBA{R: 240, G: 180, B: 0, A: 255}, RouteCost: FreeFlowTime},
+	Motorbike: {MinSpeed: 90, MaxSpeed: 599, Length: 0.002, Color: color.RGBA{R: 40, G: 40, B: 40, A: 255}, RouteCost: InheritedCost},
+}
+
+// Class returns the class of the type, the one of Car for unknown types
+func (t VehicleType) Class() VehicleClass {
+	if class, exist := vehicleClasses[t]; exist {
+		return class
+	}
+	return vehicleClasses[Car]
+}
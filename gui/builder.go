@@ -16,6 +16,10 @@ import (
 	"github.com/bisoncorp/autostrade/sampledata"
 	"image/color"
 	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"	
 )
 
@@ -24,7 +28,7 @@ func buildSimulationUi(sim api.Simulation, window fyne.Window, application *Appl
 	simulationSpeedableController := controller.NewSpeedableController(sim)
 	hintController, hintObject := controller.NewHintController()
 
-	leftCnt, addCity := buildCityPropertiesContainer(window)
+	leftCnt, addCity := buildCityPropertiesContainer(sim, window)
 	rightCnt, addVehicle := buildVehiclesPropertiesContainer(window)
 
 	mapObject, mapWidget := buildMap()
@@ -65,7 +69,7 @@ func buildMap() (fyne.CanvasObject, *gamewid.Map) {
 	return scroll, mapWidget
 }
 
-func buildCityPropertiesContainer(window fyne.Window) (obj fyne.CanvasObject, add func(api.City)) {
+func buildCityPropertiesContainer(sim api.Simulation, window fyne.Window) (obj fyne.CanvasObject, add func(api.City)) {
 	cities := make(map[api.City]int)
 	accordion := widget.NewAccordion()
 	addCity := func(city api.City) {
@@ -76,7 +80,7 @@ func buildCityPropertiesContainer(window fyne.Window) (obj fyne.CanvasObject, ad
 
 		title := fmt.Sprintf("City Property [%s]", city.Name())
 		item := widget.NewAccordionItem(title, nil)
-		content := buildCityProperty(city, window)
+		content, closeView := buildCityProperty(sim, city, window)
 		closeBtn := widget.NewButtonWithIcon("Close", theme.CancelIcon(), func() {
 			index := cities[city]
 			for k, v := range cities {
@@ -86,6 +90,7 @@ func buildCityPropertiesContainer(window fyne.Window) (obj fyne.CanvasObject, ad
 			}
 			delete(cities, city)
 			accordion.Remove(item)
+			closeView()
 		})
 		closeBtn.Importance = widget.LowImportance
 		item.Detail = container.NewVBox(content, closeBtn)
@@ -184,7 +189,7 @@ func buildRunnableControlBar(ctrl *controller.RunnableController) (start fyne.Ca
 	return playBtn, stopBtn
 }
 
-func buildCityProperty(city api.City, window fyne.Window) fyne.CanvasObject {
+func buildCityProperty(sim api.Simulation, city api.City, window fyne.Window) (obj fyne.CanvasObject, clear func()) {
 	nameItem := widget.NewFormItem("Name", widget.NewLabel(city.Name()))
 	pos := city.Position().ToPos32()
 	positionItem := widget.NewFormItem(
@@ -204,16 +209,79 @@ func buildCityProperty(city api.City, window fyne.Window) fyne.CanvasObject {
 		buildDurationSlider(city.GenerationTime, city.SetGenerationTime),
 	)
 
+	arrivalItem := widget.NewFormItem(
+		"Arrivals",
+		buildArrivalSelect(city.Arrival, city.SetArrival),
+	)
+	profileItem := widget.NewFormItem(
+		"Profile",
+		buildProfileButton(city.Profile, city.SetProfile, window),
+	)
+	fleetItem := widget.NewFormItem(
+		"Fleet",
+		buildFleetButton(city.Fleet, city.SetFleet, window),
+	)
+
+	capacityItem := widget.NewFormItem(
+		"Capacity",
+		buildCapacitySlider(city.Capacity, city.SetCapacity),
+	)
+
+	destinationsBtn := widget.NewButton("Edit", func() {
+		showDemandForm(sim, city, window)
+	})
+	destinationsBtn.Importance = widget.LowImportance
+	destinationsItem := widget.NewFormItem("Destinations", destinationsBtn)
+
 	start, stop := buildRunnableControlBar(controller.NewRunnableController(city))
 	stateItem := widget.NewFormItem(
 		"State",
 		container.NewHBox(stop, start),
 	)
 
-	return widget.NewForm(nameItem, positionItem, colorItem, processingItem, generationItem, stateItem)
+	queueLabel, waitLabel, rateLabel := widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel("")
+	queueItem := widget.NewFormItem("Queue", queueLabel)
+	waitItem := widget.NewFormItem("Mean Wait", waitLabel)
+	rateItem := widget.NewFormItem("Processed", rateLabel)
+	strandedLabel := widget.NewLabel("")
+	strandedItem := widget.NewFormItem("Stranded", strandedLabel)
+	dwellingLabel := widget.NewLabel("")
+	dwellingItem := widget.NewFormItem("Dwelling", dwellingLabel)
+	refreshMetrics := func() {
+		m := city.Metrics()
+		queueLabel.SetText(fmt.Sprintf("%d (max %d)", m.QueueLength, m.MaxQueueLength))
+		waitLabel.SetText(m.MeanWaitTime.Round(time.Second).String())
+		rateLabel.SetText(fmt.Sprintf("%d (%.1f/h)", m.Processed, m.ProcessedPerHour))
+		strandedLabel.SetText(fmt.Sprintf("%d", m.Stranded))
+		dwellingLabel.SetText(fmt.Sprintf("%d", m.Dwelling))
+	}
+	refreshMetrics()
+	stopCh := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second / 4)
+		for {
+			select {
+			case <-stopCh:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				refreshMetrics()
+			}
+		}
+	}()
+
+	return widget.NewForm(nameItem, positionItem, colorItem, processingItem, generationItem, arrivalItem, profileItem, fleetItem, capacityItem, destinationsItem, stateItem, queueItem, waitItem, rateItem, strandedItem, dwellingItem), func() {
+		stopCh <- struct{}{}
+		close(stopCh)
+	}
 }
 func buildVehicleProperty(vehicle api.Vehicle, window fyne.Window) (obj fyne.CanvasObject, clear func()) {
 	plateItem := widget.NewFormItem("Plate", widget.NewLabel(vehicle.Plate()))
+	vehicleType := vehicle.Type().String()
+	if vehicle.Line() != "" {
+		vehicleType = fmt.Sprintf("%s of line %s", vehicleType, vehicle.Line())
+	}
+	typeItem := widget.NewFormItem("Type", widget.NewLabel(vehicleType))
 	colorItem := widget.NewFormItem("Color", buildColorChooser(controller.NewColorableController(vehicle), window))
 	speedItem := widget.NewFormItem("Speed", buildSpeedSlider(vehicle.PreferredSpeed, vehicle.SetPreferredSpeed))
 	bar := widget.NewProgressBar()
@@ -233,7 +301,7 @@ func buildVehicleProperty(vehicle api.Vehicle, window fyne.Window) (obj fyne.Can
 		}
 	}()
 
-	return widget.NewForm(plateItem, colorItem, speedItem, progressItem), func() {
+	return widget.NewForm(plateItem, typeItem, colorItem, speedItem, progressItem), func() {
 		stopCh <- struct{}{}
 		close(stopCh)
 	}
@@ -269,6 +337,225 @@ func buildDurationSlider(get func() time.Duration, set func(duration time.Durati
 	}
 	return container.NewVBox(label, slider)
 }
+func buildArrivalSelect(get func() api.Arrival, set func(api.Arrival)) fyne.CanvasObject {
+	labels := map[api.Arrival]string{
+		api.FixedArrival:       "Fixed interval",
+		api.ExponentialArrival: "Poisson",
+		api.UniformArrival:     "Uniform",
+		api.NormalArrival:      "Normal",
+	}
+	options := make([]string, len(api.Arrivals))
+	for i, arrival := range api.Arrivals {
+		options[i] = labels[arrival]
+	}
+	sel := widget.NewSelect(options, nil)
+	sel.SetSelected(labels[get()])
+	sel.OnChanged = func(selected string) {
+		for arrival, label := range labels {
+			if label == selected {
+				set(arrival)
+			}
+		}
+	}
+	return sel
+}
+
+// buildProfileButton opens the editor of a demand profile, the profile is set when the editor is saved
+func buildProfileButton(get func() []float64, set func([]float64) bool, window fyne.Window) fyne.CanvasObject {
+	format := func(profile []float64) string {
+		if len(profile) == 0 {
+			return "Constant"
+		}
+		return "Hourly"
+	}
+	var btn *widget.Button
+	btn = widget.NewButton(format(get()), func() {
+		editor, edited := buildProfileEditor(get())
+		dialog.ShowCustomConfirm("Demand Profile", "Save", "Cancel", editor, func(confirmed bool) {
+			if confirmed && !set(edited()) {
+				dialog.ShowError(fmt.Errorf("a profile has %d finite multipliers, none negative", api.HoursPerDay), window)
+			}
+			btn.SetText(format(get()))
+		}, window)
+	})
+	btn.Importance = widget.LowImportance
+	return btn
+}
+
+// buildFleetButton opens a form with the share of every vehicle type, the fleet is set when the form is saved
+func buildFleetButton(get func() map[api.VehicleType]float64, set func(map[api.VehicleType]float64), window fyne.Window) fyne.CanvasObject {
+	format := func(fleet map[api.VehicleType]float64) string {
+		for vt, share := range fleet {
+			if vt != api.Car && share > 0 {
+				return "Mixed"
+			}
+		}
+		return "Cars"
+	}
+	var btn *widget.Button
+	btn = widget.NewButton(format(get()), func() {
+		fleet := get()
+		entries := make(map[api.VehicleType]*widget.Entry)
+		items := make([]*widget.FormItem, 0, len(api.VehicleTypes))
+		for _, vt := range api.VehicleTypes {
+			entry := widget.NewEntry()
+			entry.PlaceHolder = "0"
+			if share, exist := fleet[vt]; exist {
+				entry.SetText(strconv.FormatFloat(share, 'f', -1, 64))
+			}
+			entry.Validator = func(s string) error {
+				if s == "" {
+					return nil
+				}
+				share, err := strconv.ParseFloat(s, 64)
+				if err == nil && share < 0 {
+					err = errors.New("share is negative")
+				}
+				return err
+			}
+			entries[vt] = entry
+			items = append(items, widget.NewFormItem(vt.String(), entry))
+		}
+		form := dialog.NewForm("Fleet", "Save", "Cancel", items, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			// an empty fleet generates only cars
+			newFleet := make(map[api.VehicleType]float64)
+			for vt, entry := range entries {
+				if share, err := strconv.ParseFloat(entry.Text, 64); err == nil && share > 0 {
+					newFleet[vt] = share
+				}
+			}
+			set(newFleet)
+			btn.SetText(format(get()))
+		}, window)
+		form.Resize(fyne.NewSize(300, 0))
+		form.Show()
+	})
+	btn.Importance = widget.LowImportance
+	return btn
+}
+
+// buildProfileEditor shows a slider for every hour of the day, edited returns the profile, nil when constant
+func buildProfileEditor(profile []float64) (obj fyne.CanvasObject, edited func() []float64) {
+	const (
+		constant = "Constant"
+		commuter = "Commuter"
+		custom   = "Custom"
+	)
+	values := make([]float64, api.HoursPerDay)
+	sliders := make([]*widget.Slider, api.HoursPerDay)
+	grid := container.NewGridWithColumns(6)
+	mode := widget.NewSelect([]string{constant, commuter, custom}, nil)
+	setValues := func(profile []float64) {
+		for hour := range values {
+			values[hour] = api.ProfileFactor(profile, time.Duration(hour)*time.Hour)
+			sliders[hour].SetValue(values[hour])
+		}
+	}
+	for hour := range sliders {
+		hour := hour
+		format := func(f float64) string { return fmt.Sprintf("%02d:00 ×%.1f", hour, f) }
+		label := widget.NewLabel(format(0))
+		label.Alignment = fyne.TextAlignCenter
+		slider := widget.NewSlider(0, 3)
+		slider.Step = 0.1
+		slider.OnChanged = func(f float64) {
+			values[hour] = f
+			label.SetText(format(f))
+		}
+		sliders[hour] = slider
+		grid.Add(container.NewVBox(label, slider))
+	}
+	setValues(profile)
+
+	mode.OnChanged = func(selected string) {
+		switch selected {
+		case constant:
+			setValues(nil)
+		case commuter:
+			setValues(api.CommuterProfile())
+		}
+	}
+	if len(profile) == 0 {
+		mode.SetSelected(constant)
+	} else {
+		mode.SetSelected(custom)
+	}
+	for _, slider := range sliders {
+		onChanged := slider.OnChanged
+		slider.OnChanged = func(f float64) {
+			onChanged(f)
+			if f != 1 && mode.Selected == constant {
+				mode.SetSelected(custom)
+			}
+		}
+	}
+
+	return container.NewBorder(mode, nil, nil, nil, grid), func() []float64 {
+		if mode.Selected == constant {
+			return nil
+		}
+		profile := make([]float64, api.HoursPerDay)
+		copy(profile, values)
+		return profile
+	}
+}
+func buildCapacitySlider(get func() int, set func(value int)) fyne.CanvasObject {
+	format := func(value int) string {
+		if value == 0 {
+			return "Unlimited"
+		}
+		return fmt.Sprintf("%d vehicles", value)
+	}
+	label := widget.NewLabel(format(get()))
+	label.Alignment = fyne.TextAlignCenter
+	slider := widget.NewSlider(0, 1000)
+	slider.Step = 1
+	slider.SetValue(float64(get()))
+	slider.OnChanged = func(f float64) {
+		set(int(f))
+		label.SetText(format(int(f)))
+	}
+	return container.NewVBox(label, slider)
+}
+func buildLanesSlider(get func() int, set func(value int)) fyne.CanvasObject {
+	format := func(value int) string {
+		if value == 1 {
+			return "1 lane"
+		}
+		return fmt.Sprintf("%d lanes", value)
+	}
+	label := widget.NewLabel(format(get()))
+	label.Alignment = fyne.TextAlignCenter
+	slider := widget.NewSlider(1, 6)
+	slider.Step = 1
+	slider.SetValue(float64(get()))
+	slider.OnChanged = func(f float64) {
+		set(int(f))
+		label.SetText(format(int(f)))
+	}
+	return container.NewVBox(label, slider)
+}
+func buildFlowSlider(get func() float64, set func(value float64)) fyne.CanvasObject {
+	format := func(value float64) string {
+		if value == 0 {
+			return "Unlimited"
+		}
+		return fmt.Sprintf("%d veh/h per lane", int(value))
+	}
+	label := widget.NewLabel(format(get()))
+	label.Alignment = fyne.TextAlignCenter
+	slider := widget.NewSlider(0, 3000)
+	slider.Step = 100
+	slider.SetValue(get())
+	slider.OnChanged = func(f float64) {
+		set(f)
+		label.SetText(format(f))
+	}
+	return container.NewVBox(label, slider)
+}
 func buildSpeedSlider(get func() float64, set func(value float64)) fyne.CanvasObject {
 	format := func(value float64) string { return fmt.Sprintf("%dkm/h", int(value)) }
 	label := widget.NewLabel(format(get()))
@@ -296,7 +583,11 @@ func buildToolbar(sim api.Simulation, mapWidget *gamewid.Map, window fyne.Window
 	addRoadBtn := widget.NewButton("Add Road", nil)
 	addRoadBtn.Importance = widget.LowImportance
 
+	addIncidentBtn := widget.NewButton("Add Incident", nil)
+	addIncidentBtn.Importance = widget.LowImportance
+
 	disableAll := func() {
+		addIncidentBtn.Disable()
 		addRoadBtn.Disable()
 		addCityBtn.Disable()
 		remCityBtn.Disable()
@@ -304,6 +595,7 @@ func buildToolbar(sim api.Simulation, mapWidget *gamewid.Map, window fyne.Window
 	}
 
 	enableAll := func() {
+		addIncidentBtn.Enable()
 		addRoadBtn.Enable()
 		addCityBtn.Enable()
 		remCityBtn.Enable()
@@ -338,13 +630,20 @@ func buildToolbar(sim api.Simulation, mapWidget *gamewid.Map, window fyne.Window
 			enableAll()
 		}()
 	}
-	return container.NewHBox(addCityBtn, remCityBtn, moveCityBtn, widget.NewSeparator(), addRoadBtn)
+	addIncidentBtn.OnTapped = func() {
+		go func() {
+			disableAll()
+			<-actionAddIncident(sim, mapWidget, window, hintController)
+			enableAll()
+		}()
+	}
+	return container.NewHBox(addCityBtn, remCityBtn, moveCityBtn, widget.NewSeparator(), addRoadBtn, addIncidentBtn)
 }
 
 func buildMenu(sim api.Simulation, rc *controller.RunnableController, sc *controller.SpeedableController, window fyne.Window, application *Application) *fyne.MainMenu {
-	return fyne.NewMainMenu(buildFileMenu(sim, window, application), buildSimulationMenu(rc, sc, window))
+	return fyne.NewMainMenu(buildFileMenu(sim, window, application), buildSimulationMenu(sim, rc, sc, window))
 }
-func buildSimulationMenu(rc *controller.RunnableController, sc *controller.SpeedableController, window fyne.Window) *fyne.Menu {
+func buildSimulationMenu(sim api.Simulation, rc *controller.RunnableController, sc *controller.SpeedableController, window fyne.Window) *fyne.Menu {
 	start := fyne.NewMenuItem("Start", rc.Start)
 	start.Icon = theme.MediaPlayIcon()
 	stop := fyne.NewMenuItem("Stop", rc.Stop)
@@ -362,22 +661,102 @@ func buildSimulationMenu(rc *controller.RunnableController, sc *controller.Speed
 	})
 	speed.Icon = theme.MediaFastForwardIcon()
 
-	return fyne.NewMenu("Simulation", start, stop, speed)
+	gravity := fyne.NewMenuItem("Gravity Demand", func() {
+		dialog.ShowConfirm("Gravity Demand",
+			"Replace the generation times and the destinations of the cities named after a comune\nwith a demand proportional to their population?",
+			func(confirmed bool) {
+				if confirmed {
+					applyGravityDemand(sim)
+				}
+			}, window)
+	})
+
+	routing := fyne.NewMenuItem("Routing", func() {
+		dialog.ShowCustom("Routing", "Close", buildRoutingSettings(sim), window)
+	})
+
+	lines := fyne.NewMenuItem("Lines", func() {
+		showLinesDialog(sim, window)
+	})
+
+	incidents := fyne.NewMenuItem("Incidents", func() {
+		showIncidentsDialog(sim, window)
+	})
+
+	return fyne.NewMenu("Simulation", start, stop, speed, routing, gravity, lines, incidents)
+}
+
+// applyGravityDemand sets the generation times and the origin-destination matrix of sampledata.GravityDemand
+func applyGravityDemand(sim api.Simulation) {
+	cities, demand := sampledata.GravityDemand(sim.PackData().Cities, sampledata.GravityOptions{})
+	for _, cd := range cities {
+		if c := sim.City(cd.Name); c != nil && cd.GenerationTime > 0 {
+			c.SetGenerationTime(cd.GenerationTime)
+		}
+	}
+	sim.SetDemand(demand)
+}
+func buildRoutingSettings(sim api.Simulation) fyne.CanvasObject {
+	return widget.NewForm(
+		widget.NewFormItem("Route Cost", buildRouteCostChooser(sim)),
+		widget.NewFormItem("Rerouting", buildComplianceSlider(sim.Compliance, sim.SetCompliance)),
+	)
+}
+func buildComplianceSlider(get func() float64, set func(value float64)) fyne.CanvasObject {
+	format := func(value float64) string {
+		if value == 0 {
+			return "Disabled"
+		}
+		return fmt.Sprintf("%d%% of new drivers", int(math.Round(value*100)))
+	}
+	label := widget.NewLabel(format(get()))
+	label.Alignment = fyne.TextAlignCenter
+	slider := widget.NewSlider(0, 1)
+	slider.Step = 0.05
+	slider.SetValue(get())
+	slider.OnChanged = func(f float64) {
+		set(f)
+		label.SetText(format(f))
+	}
+	return container.NewVBox(label, slider)
+}
+func buildRouteCostChooser(sim api.Simulation) fyne.CanvasObject {
+	labels := map[api.RouteCost]string{
+		api.CongestedTime: "Congested travel time",
+		api.FreeFlowTime:  "Free-flow travel time",
+		api.RoadLength:    "Distance",
+	}
+	options := make([]string, len(api.RouteCosts))
+	for i, cost := range api.RouteCosts {
+		options[i] = labels[cost]
+	}
+	radio := widget.NewRadioGroup(options, nil)
+	radio.Required = true
+	radio.SetSelected(labels[sim.RouteCost()])
+	radio.OnChanged = func(selected string) {
+		for cost, label := range labels {
+			if label == selected {
+				sim.SetRouteCost(cost)
+			}
+		}
+	}
+	return radio
 }
 func buildFileMenu(sim api.Simulation, window fyne.Window, application *Application) *fyne.Menu {
 	var writer io.Writer
 	saveWithName := func() {
 		dialog.ShowFileSave(func(file fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
 			if file == nil {
 				return
 			}
 			writer = file
-			data := sim.PackData()
-			data.Vehicles = make([]struct {
-				api.VehicleData
-				RoadIndex int
-			}, 0)
-			api.WriteSimulationData(data, writer)
+			if err = api.EncodeSimulationData(sim.PackData(), writer); err != nil {
+				dialog.ShowError(err, window)
+			}
 		}, window)
 	}
 	saveWithNameItem := fyne.NewMenuItem("Save With Name", saveWithName)
@@ -388,22 +767,27 @@ func buildFileMenu(sim api.Simulation, window fyne.Window, application *Applicat
 			saveWithName()
 			return
 		}
-		data := sim.PackData()
-		data.Vehicles = make([]struct {
-			api.VehicleData
-			RoadIndex int
-		}, 0)
-		api.WriteSimulationData(data, writer)
-
+		if err := api.EncodeSimulationData(sim.PackData(), writer); err != nil {
+			dialog.ShowError(err, window)
+		}
 	})
 	saveItem.Icon = theme.DocumentSaveIcon()
 
 	openItem := fyne.NewMenuItem("Open", func() {
 		dialog.ShowFileOpen(func(file fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
 			if file == nil {
 				return
 			}
-			data := api.ReadSimulationData(file)
+			data, err := api.DecodeSimulationData(file)
+			_ = file.Close()
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
 			ns := game.NewFromData(data)
 			application.NewWindow(ns)
 		}, window)
@@ -415,7 +799,364 @@ func buildFileMenu(sim api.Simulation, window fyne.Window, application *Applicat
 	})
 	newItem.Icon = theme.ContentAddIcon()
 
-	return fyne.NewMenu("File", saveItem, saveWithNameItem, openItem, newItem)
+	newGravityItem := fyne.NewMenuItem("New Gravity Scenario", func() {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		data := sampledata.GravityScenario(20, gamewid.MapWidth, gamewid.MapHeight, sampledata.GravityOptions{}, rng)
+		application.NewWindow(game.NewFromData(data))
+	})
+	newGravityItem.Icon = theme.ContentAddIcon()
+
+	importDemandItem := fyne.NewMenuItem("Import Demand", func() {
+		dialog.ShowFileOpen(func(file fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if file == nil {
+				return
+			}
+			demand, err := api.ReadDemandCSV(file)
+			_ = file.Close()
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			sim.SetDemand(demand)
+			if ignored := len(demand) - len(sim.Demand()); ignored > 0 {
+				dialog.ShowInformation("Import Demand", fmt.Sprintf("%d pairs with unknown cities were ignored", ignored), window)
+			}
+		}, window)
+	})
+	importDemandItem.Icon = theme.UploadIcon()
+
+	return fyne.NewMenu("File", saveItem, saveWithNameItem, openItem, newItem, newGravityItem, fyne.NewMenuItemSeparator(), importDemandItem)
+}
+
+// showDemandForm edits the weights of the destinations of the vehicles generated in city
+func showDemandForm(sim api.Simulation, city api.City, window fyne.Window) {
+	demand := sim.Demand()
+	weights := make(map[string]float64)
+	for _, d := range demand {
+		if d.Origin == city.Name() {
+			weights[d.Destination] = d.Weight
+		}
+	}
+
+	entries := make(map[string]*widget.Entry)
+	items := make([]*widget.FormItem, 0)
+	for _, cd := range sim.PackData().Cities {
+		if cd.Name == city.Name() {
+			continue
+		}
+		entry := widget.NewEntry()
+		entry.PlaceHolder = "0"
+		if w, exist := weights[cd.Name]; exist {
+			entry.SetText(strconv.FormatFloat(w, 'f', -1, 64))
+		}
+		entry.Validator = func(s string) error {
+			if s == "" {
+				return nil
+			}
+			w, err := strconv.ParseFloat(s, 64)
+			if err == nil && w < 0 {
+				err = errors.New("weight is negative")
+			}
+			return err
+		}
+		entries[cd.Name] = entry
+		items = append(items, widget.NewFormItem(cd.Name, entry))
+	}
+	if len(items) == 0 {
+		dialog.ShowInformation("Destinations", "There is no other city", window)
+		return
+	}
+
+	title := fmt.Sprintf("Destinations from %s", city.Name())
+	form := dialog.NewForm(title, "Save", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		// the other origins are kept, a city without weights picks uniformly random destinations
+		newDemand := make([]api.Demand, 0, len(demand))
+		for _, d := range demand {
+			if d.Origin != city.Name() {
+				newDemand = append(newDemand, d)
+			}
+		}
+		for name, entry := range entries {
+			if w, err := strconv.ParseFloat(entry.Text, 64); err == nil && w > 0 {
+				newDemand = append(newDemand, api.Demand{ODPair: api.ODPair{Origin: city.Name(), Destination: name}, Weight: w})
+			}
+		}
+		sim.SetDemand(newDemand)
+	}, window)
+	form.Resize(fyne.NewSize(400, 0))
+	form.Show()
+}
+
+// showLinesDialog lists the public transport lines, new lines are added with showLineForm
+func showLinesDialog(sim api.Simulation, window fyne.Window) {
+	var lines []api.Line
+	describe := func(l api.Line) string {
+		stops := l.Stops()
+		names := make([]string, len(stops))
+		for i, c := range stops {
+			names[i] = c.Name()
+		}
+		schedule := "no departures"
+		if l.Headway() > 0 {
+			schedule = "every " + l.Headway().String()
+		} else if departures := l.Departures(); len(departures) > 0 {
+			schedule = fmt.Sprintf("%d departures a day", len(departures))
+		}
+		return fmt.Sprintf("%s (%s): %s, %s", l.Name(), l.Type(), strings.Join(names, " - "), schedule)
+	}
+	list := widget.NewList(
+		func() int { return len(lines) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) { obj.(*widget.Label).SetText(describe(lines[id])) },
+	)
+	refresh := func() {
+		lines = sim.Lines()
+		list.UnselectAll()
+		list.Refresh()
+	}
+	refresh()
+	selected := -1
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	addBtn := widget.NewButtonWithIcon("New", theme.ContentAddIcon(), func() {
+		showLineForm(sim, window, refresh)
+	})
+	remBtn := widget.NewButtonWithIcon("Remove", theme.ContentRemoveIcon(), func() {
+		if selected >= 0 && selected < len(lines) {
+			sim.RemoveLine(lines[selected])
+			refresh()
+		}
+	})
+	content := container.NewBorder(nil, container.NewHBox(addBtn, remBtn), nil, nil, list)
+	d := dialog.NewCustom("Lines", "Close", content, window)
+	d.Resize(fyne.NewSize(500, 300))
+	d.Show()
+}
+
+// showLineForm adds a public transport line, the departures are times of the day such as "07:30" separated by commas
+// and replace the headway when given
+func showLineForm(sim api.Simulation, window fyne.Window, onAdded func()) {
+	nameEntry := widget.NewEntry()
+	nameEntry.Validator = func(s string) error {
+		if s == "" {
+			return errors.New("name is empty")
+		}
+		if sim.Line(s) != nil {
+			return errors.New("line already exist")
+		}
+		return nil
+	}
+	stopsEntry := widget.NewEntry()
+	stopsEntry.PlaceHolder = "Roma, Firenze, Milano"
+	stopsEntry.Validator = func(s string) error {
+		stops := splitList(s)
+		if len(stops) < 2 {
+			return errors.New("a line needs two stops")
+		}
+		for i, stop := range stops {
+			if sim.City(stop) == nil {
+				return fmt.Errorf("unknown city %q", stop)
+			}
+			if i == 0 {
+				continue
+			}
+			if road, _ := sim.Road(stops[i-1], stop); road == nil {
+				return fmt.Errorf("no road from %q to %q", stops[i-1], stop)
+			}
+		}
+		return nil
+	}
+
+	typeOptions := make([]string, len(api.VehicleTypes))
+	for i, vt := range api.VehicleTypes {
+		typeOptions[i] = vt.String()
+	}
+	typeSelect := widget.NewSelect(typeOptions, nil)
+	typeSelect.SetSelected(api.Bus.String())
+
+	headway, dwell := 15*time.Minute, 30*time.Second
+	headwayItem := widget.NewFormItem("Headway", buildDurationSlider(func() time.Duration {
+		return headway
+	}, func(duration time.Duration) {
+		headway = duration
+	}))
+	departuresEntry := widget.NewEntry()
+	departuresEntry.PlaceHolder = "07:00, 07:30, 18:00"
+	departuresEntry.Validator = func(s string) error {
+		_, err := parseDepartures(s)
+		return err
+	}
+	dwellItem := widget.NewFormItem("Dwell Time", buildDurationSlider(func() time.Duration {
+		return dwell
+	}, func(duration time.Duration) {
+		dwell = duration
+	}))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Stops", stopsEntry),
+		widget.NewFormItem("Vehicles", typeSelect),
+		headwayItem,
+		widget.NewFormItem("Departures", departuresEntry),
+		dwellItem,
+	}
+	form := dialog.NewForm("New Line", "Add", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		vt, _ := api.ParseVehicleType(typeSelect.Selected)
+		data := api.LineData{
+			Name:    nameEntry.Text,
+			Type:    vt,
+			Color:   colorToRgba(randomColor()),
+			Stops:   splitList(stopsEntry.Text),
+			Headway: headway,
+			Dwell:   dwell,
+		}
+		if departures, _ := parseDepartures(departuresEntry.Text); len(departures) > 0 {
+			data.Headway, data.Departures = 0, departures
+		}
+		if sim.AddLine(data) == nil {
+			dialog.ShowError(errors.New("the line cannot be added"), window)
+			return
+		}
+		onAdded()
+	}, window)
+	form.Resize(fyne.NewSize(400, 0))
+	form.Show()
+}
+
+// showIncidentsDialog lists the incidents, the selected one is removed to reopen its road
+func showIncidentsDialog(sim api.Simulation, window fyne.Window) {
+	var incidents []api.Incident
+	describe := func(i api.Incident) string {
+		effect := "closed"
+		if i.Capacity > 0 {
+			effect = fmt.Sprintf("%d%% of capacity", int(math.Round(i.Capacity*100)))
+		}
+		return fmt.Sprintf("%s - %s: %s from %v to %v", i.Src, i.Dst, effect, i.Start.Round(time.Second), i.End.Round(time.Second))
+	}
+	list := widget.NewList(
+		func() int { return len(incidents) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(describe(incidents[id]))
+		},
+	)
+	refresh := func() {
+		incidents = sim.Incidents()
+		list.UnselectAll()
+		list.Refresh()
+	}
+	refresh()
+	selected := -1
+	list.OnSelected = func(id widget.ListItemID) { selected = id }
+	list.OnUnselected = func(widget.ListItemID) { selected = -1 }
+
+	remBtn := widget.NewButtonWithIcon("Remove", theme.ContentRemoveIcon(), func() {
+		if selected >= 0 && selected < len(incidents) {
+			sim.SetIncidents(append(incidents[:selected:selected], incidents[selected+1:]...))
+			refresh()
+		}
+	})
+	content := container.NewBorder(nil, container.NewHBox(remBtn), nil, nil, list)
+	d := dialog.NewCustom("Incidents", "Close", content, window)
+	d.Resize(fyne.NewSize(500, 300))
+	d.Show()
+}
+
+// showIncidentForm returns the incident starting after the chosen delay, the road is chosen on the map
+func showIncidentForm(sim api.Simulation, window fyne.Window) <-chan struct {
+	incident api.Incident
+	bothWays bool
+} {
+	delay, duration := time.Second/10, 30*time.Minute
+	delayItem := widget.NewFormItem("Starts In", buildDurationSlider(func() time.Duration {
+		return delay
+	}, func(d time.Duration) {
+		delay = d
+	}))
+	durationItem := widget.NewFormItem("Duration", buildDurationSlider(func() time.Duration {
+		return duration
+	}, func(d time.Duration) {
+		duration = d
+	}))
+
+	capacity := float64(0)
+	format := func(value float64) string {
+		if value == 0 {
+			return "Closed"
+		}
+		return fmt.Sprintf("%d%% of capacity", int(math.Round(value*100)))
+	}
+	label := widget.NewLabel(format(capacity))
+	label.Alignment = fyne.TextAlignCenter
+	slider := widget.NewSlider(0, 0.9)
+	slider.Step = 0.1
+	slider.OnChanged = func(f float64) {
+		capacity = f
+		label.SetText(format(f))
+	}
+	capacityItem := widget.NewFormItem("Capacity", container.NewVBox(label, slider))
+
+	bothWays := true
+	check := widget.NewCheck("Both Ways?", func(b bool) {
+		bothWays = b
+	})
+	check.SetChecked(bothWays)
+	bothWaysItem := widget.NewFormItem("", check)
+
+	items := []*widget.FormItem{delayItem, durationItem, capacityItem, bothWaysItem}
+	ch := make(chan struct {
+		incident api.Incident
+		bothWays bool
+	}, 1)
+	dialog.ShowForm("New Incident", "Next", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			close(ch)
+			return
+		}
+		start := sim.Clock().Now() + delay
+		ch <- struct {
+			incident api.Incident
+			bothWays bool
+		}{incident: api.Incident{Start: start, End: start + duration, Capacity: capacity}, bothWays: bothWays}
+		close(ch)
+	}, window)
+
+	return ch
+}
+
+// splitList returns the trimmed non-empty elements of a comma separated list
+func splitList(s string) []string {
+	elements := make([]string, 0)
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+// parseDepartures parses a comma separated list of times of the day formatted as 15:04
+func parseDepartures(s string) ([]time.Duration, error) {
+	departures := make([]time.Duration, 0)
+	for _, e := range splitList(s) {
+		t, err := time.Parse("15:04", e)
+		if err != nil {
+			return nil, fmt.Errorf("departure %q is not formatted as 15:04", e)
+		}
+		departures = append(departures, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+	return departures, nil
 }
 
 func showCityForm(sim api.Simulation, window fyne.Window) <-chan api.CityData {
@@ -436,7 +1177,7 @@ func showCityForm(sim api.Simulation, window fyne.Window) <-chan api.CityData {
 		buildColorChooser(colorBuffer, window),
 	)
 
-	processingDuration := time.Millisecond * 100
+	processingDuration := time.Second * 10
 	processingItem := widget.NewFormItem(
 		"Processing Time",
 		buildDurationSlider(func() time.Duration {
@@ -446,7 +1187,7 @@ func showCityForm(sim api.Simulation, window fyne.Window) <-chan api.CityData {
 		}),
 	)
 
-	generationDuration := time.Millisecond * 500
+	generationDuration := time.Minute * 5
 	generationItem := widget.NewFormItem(
 		"Generation Time",
 		buildDurationSlider(func() time.Duration {
@@ -456,7 +1197,51 @@ func showCityForm(sim api.Simulation, window fyne.Window) <-chan api.CityData {
 		}),
 	)
 
-	items := []*widget.FormItem{nameItem, colorItem, processingItem, generationItem}
+	capacity := 0
+	capacityItem := widget.NewFormItem(
+		"Capacity",
+		buildCapacitySlider(func() int {
+			return capacity
+		}, func(value int) {
+			capacity = value
+		}),
+	)
+
+	arrival := api.FixedArrival
+	arrivalItem := widget.NewFormItem(
+		"Arrivals",
+		buildArrivalSelect(func() api.Arrival {
+			return arrival
+		}, func(value api.Arrival) {
+			arrival = value
+		}),
+	)
+
+	var profile []float64
+	profileItem := widget.NewFormItem(
+		"Profile",
+		buildProfileButton(func() []float64 {
+			return profile
+		}, func(value []float64) bool {
+			if !api.ValidProfile(value) {
+				return false
+			}
+			profile = value
+			return true
+		}, window),
+	)
+
+	var fleet map[api.VehicleType]float64
+	fleetItem := widget.NewFormItem(
+		"Fleet",
+		buildFleetButton(func() map[api.VehicleType]float64 {
+			return fleet
+		}, func(value map[api.VehicleType]float64) {
+			fleet = value
+		}, window),
+	)
+
+	items := []*widget.FormItem{nameItem, colorItem, processingItem, generationItem, arrivalItem, profileItem, fleetItem, capacityItem}
 	ch := make(chan api.CityData, 1)
 	dialog.ShowForm("New City", "Choose Position", "Cancel", items, func(confirmed bool) {
 		if !confirmed {
@@ -471,7 +1256,11 @@ func showCityForm(sim api.Simulation, window fyne.Window) <-chan api.CityData {
 			Name:           name,
 			Color:          colorToRgba(colorBuffer.Color()),
 			GenerationTime: generationDuration,
+			Arrival:        arrival,
 			ProcessingTime: processingDuration,
+			Capacity:       capacity,
+			Profile:        profile,
+			Fleet:          fleet,
 		}
 		close(ch)
 	}, window)
@@ -490,13 +1279,27 @@ func showRoadForm(sim api.Simulation, window fyne.Window) <-chan struct {
 	})
 	maxSpeedItem := widget.NewFormItem("Max Speed", slider)
 
+	lanes := 1
+	lanesItem := widget.NewFormItem("Lanes", buildLanesSlider(func() int {
+		return lanes
+	}, func(value int) {
+		lanes = value
+	}))
+
 	oneWay := false
 	check := widget.NewCheck("One Way?", func(b bool) {
 		oneWay = b
 	})
 	oneWayItem := widget.NewFormItem("", check)
 
-	items := []*widget.FormItem{maxSpeedItem, oneWayItem}
+	capacity := float64(0)
+	capacityItem := widget.NewFormItem("Capacity", buildFlowSlider(func() float64 {
+		return capacity
+	}, func(value float64) {
+		capacity = value
+	}))
+
+	items := []*widget.FormItem{maxSpeedItem, lanesItem, capacityItem, oneWayItem}
 	ch := make(chan struct {
 		data   api.RoadData
 		oneWay bool
@@ -509,7 +1312,7 @@ func showRoadForm(sim api.Simulation, window fyne.Window) <-chan struct {
 		ch <- struct {
 			data   api.RoadData
 			oneWay bool
-		}{data: api.RoadData{MaxSpeed: maxSpeed}, oneWay: oneWay}
+		}{data: api.RoadData{MaxSpeed: maxSpeed, Lanes: lanes, Capacity: capacity}, oneWay: oneWay}
 		close(ch)
 	}, window)
 
@@ -589,6 +1392,41 @@ func actionAddRoad(sim api.Simulation, mapWidget *gamewid.Map, window fyne.Windo
 	}()
 	return
 }
+func actionAddIncident(sim api.Simulation, mapWidget *gamewid.Map, window fyne.Window, hintController *controller.HintController) (done chan struct{}) {
+	done = make(chan struct{}, 1)
+	go func() {
+		defer close(done)
+		dataCh := showIncidentForm(sim, window)
+		data, ok := <-dataCh
+		if !ok {
+			return
+		}
+
+		cityCh := make(chan string)
+		defer close(cityCh)
+		oldFn := mapWidget.OnCityTapped
+		mapWidget.OnCityTapped = func(data api.CityData) {
+			cityCh <- data.Name
+		}
+		defer func() { mapWidget.OnCityTapped = oldFn }()
+
+		hintController.SetHint("Select first city")
+		data.incident.Src = <-cityCh
+		hintController.SetHint("Select second city")
+		data.incident.Dst = <-cityCh
+		hintController.Clear()
+		added := sim.AddIncident(data.incident)
+		if data.bothWays {
+			reverse := data.incident
+			reverse.Src, reverse.Dst = data.incident.Dst, data.incident.Src
+			added = sim.AddIncident(reverse) || added
+		}
+		if !added {
+			dialog.ShowError(fmt.Errorf("there is no road from %s to %s", data.incident.Src, data.incident.Dst), window)
+		}
+	}()
+	return
+}
 func actionRemCity(sim api.Simulation, mapWidget *gamewid.Map, hintController *controller.HintController) (done chan struct{}) {
 	done = make(chan struct{}, 1)
 	go func() {
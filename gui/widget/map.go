@@ -102,7 +102,7 @@ func (m *mapRenderer) Layout(size fyne.Size) {
 	m.cities = refreshCityObjects(m.cities, data.Cities, func(data api.CityData) {
 		m.wid.callOnCityTapped(data)
 	})
-	m.roads = refreshRoadObjects(m.roads, data.Roads, data.Cities)
+	m.roads = refreshRoadObjects(m.roads, data.Roads, data.Cities, data.Incidents, data.Clock.Now)
 	m.vehicles = refreshVehicleObjects(m.vehicles, data.Vehicles, func(data api.VehicleData) {
 		m.wid.callOnVehicleTapped(data)
 	})
@@ -169,6 +169,8 @@ func refreshRoadObjects(
 		SrcIndex, DstIndex int
 	},
 	citiesData []api.CityData,
+	incidents []api.Incident,
+	now time.Duration,
 ) []fyne.CanvasObject {
 	objects := make([]fyne.CanvasObject, len(roadsData))
 	for i := 0; i < len(objects); i++ {
@@ -177,7 +179,8 @@ func refreshRoadObjects(
 		} else {
 			objects[i] = NewRoad()
 		}
-		objects[i].(*Road).SetData(roadsData[i].RoadData, citiesData[roadsData[i].SrcIndex], citiesData[roadsData[i].DstIndex])
+		src, dst := citiesData[roadsData[i].SrcIndex], citiesData[roadsData[i].DstIndex]
+		objects[i].(*Road).SetData(roadsData[i].RoadData, src, dst, api.CapacityLeft(incidents, src.Name, dst.Name, now))
 	}
 	return objects
 }
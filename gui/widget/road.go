@@ -12,10 +12,12 @@ type Road struct {
 	widget.BaseWidget
 	data     api.RoadData
 	src, dst api.CityData
+	// capacityLeft is the fraction of capacity left by the incidents, the road is closed at zero
+	capacityLeft float64
 }
 
-func (r *Road) SetData(data api.RoadData, src, dst api.CityData) {
-	r.data, r.src, r.dst = data, src, dst
+func (r *Road) SetData(data api.RoadData, src, dst api.CityData, capacityLeft float64) {
+	r.data, r.src, r.dst, r.capacityLeft = data, src, dst, capacityLeft
 	r.Refresh()
 }
 
@@ -43,6 +45,19 @@ type roadRenderer struct {
 
 func (r *roadRenderer) Destroy() {}
 func (r *roadRenderer) Layout(_ fyne.Size) {
+	lanes := r.wid.data.Lanes
+	if lanes < 1 {
+		lanes = 1
+	}
+	r.line.StrokeWidth = roadDimension * float32(lanes)
+	switch {
+	case r.wid.capacityLeft <= 0:
+		r.line.StrokeColor = theme.ErrorColor()
+	case r.wid.capacityLeft < 1:
+		r.line.StrokeColor = theme.WarningColor()
+	default:
+		r.line.StrokeColor = theme.ForegroundColor()
+	}
 	r.line.Position1 = scale(r.wid.src.Pos.ToPos32(), scaleFactor)
 	r.line.Position2 = scale(r.wid.dst.Pos.ToPos32(), scaleFactor)
 	r.line.Refresh()
@@ -40,11 +40,14 @@ func (v *Vehicle) Tapped(_ *fyne.PointEvent) {
 func (v *Vehicle) CreateRenderer() fyne.WidgetRenderer {
 	circle := canvas.NewCircle(color.Transparent)
 	hoverCircle := canvas.NewCircle(color.Transparent)
+	square := canvas.NewRectangle(color.Transparent)
+	square.StrokeWidth = 1
 	return &vehicleRenderer{
 		wid:         v,
-		objects:     []fyne.CanvasObject{hoverCircle, circle},
+		objects:     []fyne.CanvasObject{hoverCircle, circle, square},
 		circle:      circle,
 		hoverCircle: hoverCircle,
+		square:      square,
 	}
 }
 
@@ -54,10 +57,12 @@ func NewVehicle(onTapped func(data api.VehicleData)) *Vehicle {
 	return v
 }
 
+// vehicleRenderer draws the vehicles of a public transport line as a square and the others as a circle
 type vehicleRenderer struct {
 	wid                 *Vehicle
 	objects             []fyne.CanvasObject
 	circle, hoverCircle *canvas.Circle
+	square              *canvas.Rectangle
 }
 
 func (v *vehicleRenderer) Destroy() {}
@@ -65,6 +70,8 @@ func (v *vehicleRenderer) Layout(size fyne.Size) {
 	v.hoverCircle.Resize(size)
 	v.circle.Resize(size.SubtractWidthHeight(theme.Padding(), theme.Padding()))
 	v.circle.Move(fyne.NewPos(theme.Padding()/2, theme.Padding()/2))
+	v.square.Resize(v.circle.Size())
+	v.square.Move(v.circle.Position())
 }
 func (v *vehicleRenderer) MinSize() fyne.Size {
 	return fyne.NewSize(vehicleDimension+theme.Padding(), vehicleDimension+theme.Padding())
@@ -79,8 +86,18 @@ func (v *vehicleRenderer) Refresh() {
 		v.hoverCircle.Hide()
 	}
 	col := v.wid.data.Color
+	if v.wid.data.Line != "" {
+		v.circle.Hide()
+		v.square.Show()
+	} else {
+		v.square.Hide()
+		v.circle.Show()
+	}
 	v.circle.FillColor = col
 	v.circle.Refresh()
+	v.square.FillColor = col
+	v.square.StrokeColor = theme.ForegroundColor()
+	v.square.Refresh()
 	v.hoverCircle.FillColor = hoverColor(col)
 	v.hoverCircle.Refresh()
 }
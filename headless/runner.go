@@ -0,0 +1,167 @@
+package headless
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bisoncorp/autostrade/game"
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Options override the scenario settings, zero values keep the ones stored in the scenario
+type Options struct {
+	Duration  time.Duration
+	Speed     float64
+	Clock     string
+	Step      time.Duration
+	Seed      int64
+	RouteCost string
+	// Compliance is nil to keep the scenario one, so that zero disables rerouting
+	Compliance *float64
+	OutDir     string
+}
+
+type Summary struct {
+	Scenario           string
+	Seed               int64
+	Clock              string
+	SimulatedTime      time.Duration
+	WallTime           time.Duration
+	Cities             int
+	Roads              int
+	VehiclesGenerated  int
+	VehiclesTravelling int
+	VehiclesStranded   int
+	TripsCompleted     int
+	MeanTravelTime     time.Duration
+	TravelTimeP95      time.Duration
+	Throughput         float64 // trips completed per simulated hour
+}
+
+// Run loads the scenario at path, runs it for opts.Duration of simulated time without any window
+// and writes the summary and the final state in opts.OutDir
+func Run(path string, opts Options) (Summary, error) {
+	if opts.Duration <= 0 {
+		return Summary{}, errors.New("headless: duration must be positive")
+	}
+	data, err := readScenario(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	if err = applyOptions(&data, opts); err != nil {
+		return Summary{}, err
+	}
+
+	sim := game.NewFromData(data)
+	wallStart := time.Now()
+	if sim.Clock().Mode() == api.FixedStep {
+		sim.Advance(opts.Duration)
+	} else {
+		if sim.Speed() <= 0 {
+			return Summary{}, errors.New("headless: speed must be positive in realtime mode")
+		}
+		sim.Start()
+		time.Sleep(time.Duration(float64(opts.Duration) / sim.Speed()))
+		sim.Stop()
+	}
+	wallTime := time.Since(wallStart)
+
+	final := sim.PackData()
+	stats := sim.Stats()
+	summary := Summary{
+		Scenario:           path,
+		Seed:               final.Seed,
+		Clock:              final.Clock.Mode.String(),
+		SimulatedTime:      final.Clock.Now - data.Clock.Now,
+		WallTime:           wallTime,
+		Cities:             len(final.Cities),
+		Roads:              len(final.Roads),
+		VehiclesGenerated:  final.LastPlate.Ordinal() - data.LastPlate.Ordinal(),
+		VehiclesTravelling: len(final.Vehicles),
+		TripsCompleted:     stats.Completed,
+		MeanTravelTime:     stats.MeanTravelTime(),
+		TravelTimeP95:      stats.TravelTimePercentile(95),
+		Throughput:         stats.Throughput(),
+	}
+	for _, c := range final.Cities {
+		summary.VehiclesStranded += sim.City(c.Name).Metrics().Stranded
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err = writeJson(filepath.Join(opts.OutDir, base+".summary.json"), summary); err != nil {
+		return summary, err
+	}
+	err = writeFile(filepath.Join(opts.OutDir, base+".state.json"), func(file *os.File) error {
+		return api.EncodeSimulationData(final, file)
+	})
+	return summary, err
+}
+
+func applyOptions(data *api.SimulationData, opts Options) error {
+	if opts.Speed > 0 {
+		data.Speed = opts.Speed
+	}
+	if opts.Clock != "" {
+		mode, err := api.ParseClockMode(opts.Clock)
+		if err != nil {
+			return err
+		}
+		data.Clock.Mode = mode
+	}
+	if opts.Step > 0 {
+		data.Clock.Step = opts.Step
+	}
+	if opts.Seed != 0 {
+		data.Seed = opts.Seed
+	}
+	if opts.RouteCost != "" {
+		cost, err := api.ParseRouteCost(opts.RouteCost)
+		if err != nil {
+			return err
+		}
+		data.RouteCost = cost
+	}
+	if opts.Compliance != nil {
+		if c := *opts.Compliance; !(c >= 0 && c <= 1) {
+			return fmt.Errorf("headless: compliance %v is not in [0, 1]", c)
+		}
+		data.Compliance = *opts.Compliance
+	}
+	return nil
+}
+
+func readScenario(path string) (data api.SimulationData, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	return api.DecodeSimulationData(file)
+}
+
+func writeJson(path string, v any) error {
+	return writeFile(path, func(file *os.File) error {
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "\t")
+		return enc.Encode(v)
+	})
+}
+
+func writeFile(path string, write func(*os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("headless: writing %s: %w", path, err)
+	}
+	return file.Close()
+}
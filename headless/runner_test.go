@@ -0,0 +1,95 @@
+package headless
+
+import (
+	"encoding/json"
+	"github.com/bisoncorp/autostrade/game"
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyCompliance(t *testing.T) {
+	data := api.SimulationData{Compliance: 0.5}
+	if err := applyOptions(&data, Options{}); err != nil || data.Compliance != 0.5 {
+		t.Errorf("compliance %v without override, %v", data.Compliance, err)
+	}
+	zero := 0.0
+	if err := applyOptions(&data, Options{Compliance: &zero}); err != nil || data.Compliance != 0 {
+		t.Errorf("compliance %v overridden by zero, %v", data.Compliance, err)
+	}
+	for _, c := range []float64{-0.1, 1.5, math.NaN()} {
+		if err := applyOptions(&data, Options{Compliance: &c}); err == nil {
+			t.Errorf("compliance %v accepted", c)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	scenario := api.SimulationData{
+		Speed:     1,
+		Clock:     api.ClockData{Mode: api.FixedStep, Step: time.Second},
+		Seed:      42,
+		LastPlate: api.FirstPlate,
+		Cities: []api.CityData{
+			{Name: "Roma", GenerationTime: time.Minute, ProcessingTime: time.Second},
+			{Name: "Milano", Pos: api.Position{X: 100}, GenerationTime: 2 * time.Minute, ProcessingTime: time.Second},
+		},
+	}
+	for _, r := range [][2]int{{0, 1}, {1, 0}} {
+		scenario.Roads = append(scenario.Roads, struct {
+			api.RoadData
+			SrcIndex, DstIndex int
+		}{RoadData: api.RoadData{MaxSpeed: 130, Lanes: 1}, SrcIndex: r[0], DstIndex: r[1]})
+	}
+	path := filepath.Join(dir, "scenario.json")
+	if err := writeFile(path, func(file *os.File) error { return api.EncodeSimulationData(scenario, file) }); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if _, err := Run(path, Options{OutDir: out}); err == nil {
+		t.Error("scenario run without a duration")
+	}
+	summary, err := Run(path, Options{Duration: 2 * time.Hour, OutDir: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Scenario != path || summary.Seed != 42 || summary.Clock != api.FixedStep.String() || summary.SimulatedTime != 2*time.Hour {
+		t.Errorf("unexpected run %+v", summary)
+	}
+	if summary.Cities != 2 || summary.Roads != 2 || summary.VehiclesGenerated == 0 || summary.TripsCompleted == 0 {
+		t.Errorf("unexpected counts %+v", summary)
+	}
+	if summary.Throughput != float64(summary.TripsCompleted)/2 || summary.MeanTravelTime <= 0 || summary.TravelTimeP95 < summary.MeanTravelTime/2 {
+		t.Errorf("unexpected trip stats %+v", summary)
+	}
+
+	file, err := os.Open(filepath.Join(out, "scenario.summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := Summary{}
+	err = json.NewDecoder(file).Decode(&written)
+	_ = file.Close()
+	if err != nil || written != summary {
+		t.Errorf("summary written as %+v, %v", written, err)
+	}
+
+	state, err := readScenario(filepath.Join(out, "scenario.state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = state.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if state.Clock.Now != 2*time.Hour || len(state.Vehicles) != summary.VehiclesTravelling {
+		t.Errorf("state at %v with %d vehicles", state.Clock.Now, len(state.Vehicles))
+	}
+	if reloaded := game.NewFromData(state).PackData(); len(reloaded.Vehicles) != len(state.Vehicles) || reloaded.LastPlate != state.LastPlate {
+		t.Errorf("reloaded state has %d vehicles", len(reloaded.Vehicles))
+	}
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"flag"
 	"github.com/bisoncorp/autostrade/game"
 	api "github.com/bisoncorp/autostrade/gameapi"
 	"github.com/bisoncorp/autostrade/gui"
+	"github.com/bisoncorp/autostrade/headless"
 	"log"
 	"os"
+	"strconv"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(run(os.Args[2:]))
+	}
+
 	appl := gui.NewApplication()
 	filesPath := os.Args[1:]
 	if len(filesPath) > 0 {
@@ -18,11 +25,68 @@ func main() {
 				log.Println(err)
 				continue
 			}
-			appl.NewWindow(game.NewFromData(api.ReadSimulationData(file)))
+			data, err := api.DecodeSimulationData(file)
 			_ = file.Close()
+			if err != nil {
+				log.Printf("%s: %v", path, err)
+				continue
+			}
+			appl.NewWindow(game.NewFromData(data))
 		}
 	} else {
 		appl.NewWindow(game.New())
 	}
 	appl.Run()
 }
+
+// run is the headless batch mode: autostrade run [flags] scenario.json...
+func run(args []string) int {
+	opts := headless.Options{}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.DurationVar(&opts.Duration, "duration", 0, "simulated time to run each scenario for")
+	fs.Float64Var(&opts.Speed, "speed", 0, "simulation speed, overrides the scenario one")
+	fs.StringVar(&opts.Clock, "clock", "", "clock mode (realtime or fixed), overrides the scenario one")
+	fs.DurationVar(&opts.Step, "step", 0, "tick length of the fixed clock, overrides the scenario one")
+	fs.Int64Var(&opts.Seed, "seed", 0, "random seed, overrides the scenario one")
+	fs.StringVar(&opts.RouteCost, "route-cost", "", "road cost minimised by routing (congested, freeflow or distance), overrides the scenario one")
+	fs.Func("compliance", "fraction of vehicles rerouting in every city, overrides the scenario one", func(s string) error {
+		compliance, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		opts.Compliance = &compliance
+		return nil
+	})
+	fs.StringVar(&opts.OutDir, "out", ".", "directory for summaries and final states")
+
+	// flags are accepted before and after the scenario files
+	scenarios := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		scenarios = append(scenarios, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(scenarios) == 0 {
+		log.Println("run: no scenario given")
+		fs.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, path := range scenarios {
+		summary, err := headless.Run(path, opts)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			code = 1
+			continue
+		}
+		log.Printf("%s: %v simulated in %v, %d vehicles generated, %d travelling",
+			path, summary.SimulatedTime, summary.WallTime, summary.VehiclesGenerated, summary.VehiclesTravelling)
+	}
+	return code
+}
@@ -5,12 +5,18 @@ import (
 	_ "embed"
 	"encoding/csv"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 //go:embed db_city.csv
 var dbCityCsv []byte
 var dbCity [][]string
 
+// population maps the lower case name of every comune to its inhabitants
+var population map[string]int
+
 const (
 	Istat = iota
 	Comune
@@ -32,9 +38,55 @@ func init() {
 	}
 	all = all[1:]
 	dbCity = all
+
+	population = make(map[string]int, len(dbCity))
+	for _, record := range dbCity {
+		n, err := strconv.Atoi(record[Abitanti])
+		if err != nil {
+			continue
+		}
+		if name := strings.ToLower(record[Comune]); n > population[name] {
+			population[name] = n
+		}
+	}
 }
 
 func RandomCityName() string {
 	index := rand.Intn(len(dbCity))
 	return dbCity[index][Comune]
 }
+
+// Population returns the inhabitants of the comune, the name is case-insensitive
+func Population(comune string) (int, bool) {
+	n, exist := population[strings.ToLower(comune)]
+	return n, exist
+}
+
+// MostPopulated returns the names of the n most populated comuni, most populated first
+func MostPopulated(n int) []string {
+	// comuni sharing a name are listed once
+	records := make([][]string, 0, len(dbCity))
+	listed := make(map[string]bool, len(dbCity))
+	for _, record := range dbCity {
+		name := strings.ToLower(record[Comune])
+		if _, exist := population[name]; exist && !listed[name] {
+			listed[name] = true
+			records = append(records, record)
+		}
+	}
+	inhabitants := func(record []string) int {
+		n, _ := Population(record[Comune])
+		return n
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return inhabitants(records[i]) > inhabitants(records[j])
+	})
+	if n > len(records) {
+		n = len(records)
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = records[i][Comune]
+	}
+	return names
+}
@@ -0,0 +1,172 @@
+package sampledata
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// GravityOptions tune the demand of GravityDemand and GravityScenario
+type GravityOptions struct {
+	// TripsPerHour is the number of vehicles generated by all the cities in an hour of simulated time
+	TripsPerHour float64
+	// Decay is the exponent of the distance in the gravity model, 2 when zero
+	Decay float64
+}
+
+const (
+	defaultTripsPerHour = 600
+	defaultDecay        = 2
+)
+
+// GravityDemand shares opts.TripsPerHour among the cities proportionally to their population, setting
+// their GenerationTime, and returns the origin-destination matrix of the gravity model: the trips from a
+// city to another are proportional to the product of their populations divided by their distance to the
+// power of opts.Decay. The cities not named after a comune are left unchanged and out of the matrix
+func GravityDemand(cities []api.CityData, opts GravityOptions) ([]api.CityData, []api.Demand) {
+	if opts.TripsPerHour <= 0 {
+		opts.TripsPerHour = defaultTripsPerHour
+	}
+	if opts.Decay <= 0 {
+		opts.Decay = defaultDecay
+	}
+
+	result := make([]api.CityData, len(cities))
+	copy(result, cities)
+	populations := make([]float64, len(cities))
+	total := 0.0
+	for i, c := range cities {
+		if n, exist := Population(c.Name); exist {
+			populations[i] = float64(n)
+			total += float64(n)
+		}
+	}
+	if total == 0 {
+		return result, nil
+	}
+
+	demand := make([]api.Demand, 0)
+	for i := range result {
+		if populations[i] == 0 {
+			continue
+		}
+		tripsPerHour := opts.TripsPerHour * populations[i] / total
+		result[i].GenerationTime = time.Duration(float64(time.Hour) / tripsPerHour)
+		for j := range result {
+			if i == j || populations[j] == 0 {
+				continue
+			}
+			// cities on the same spot are kept one unit apart
+			distance := math.Max(api.Distance(result[i].Pos, result[j].Pos), 1)
+			demand = append(demand, api.Demand{
+				ODPair: api.ODPair{Origin: result[i].Name, Destination: result[j].Name},
+				Weight: populations[i] * populations[j] / math.Pow(distance, opts.Decay),
+			})
+		}
+	}
+	return result, demand
+}
+
+// GravityScenario returns a simulation of the n most populated comuni with the demand of GravityDemand.
+// The comuni carry no coordinates, so they are placed at random in a width by height area, and every city
+// is connected by two-way roads to its nearest neighbours and to a spanning tree of the others
+func GravityScenario(n int, width, height float64, opts GravityOptions, rng *rand.Rand) api.SimulationData {
+	data := api.SimulationData{
+		Speed:     1,
+		Clock:     api.ClockData{Mode: api.FixedStep, Step: api.DefaultStep},
+		Seed:      rng.Int63(),
+		LastPlate: api.FirstPlate,
+	}
+	for _, name := range MostPopulated(n) {
+		data.Cities = append(data.Cities, api.CityData{
+			Name: name,
+			Color: color.RGBA{
+				R: uint8(rng.Intn(256)),
+				G: uint8(rng.Intn(256)),
+				B: uint8(rng.Intn(256)),
+				A: 255,
+			},
+			Pos:            api.Position{X: rng.Float64() * width, Y: rng.Float64() * height},
+			ProcessingTime: 10 * time.Second,
+		})
+	}
+	data.Cities, data.Demand = GravityDemand(data.Cities, opts)
+
+	for _, pair := range connect(data.Cities) {
+		for _, r := range [][2]int{pair, {pair[1], pair[0]}} {
+			data.Roads = append(data.Roads, struct {
+				api.RoadData
+				SrcIndex, DstIndex int
+			}{RoadData: api.RoadData{MaxSpeed: 130, Lanes: 2}, SrcIndex: r[0], DstIndex: r[1]})
+		}
+	}
+	return data
+}
+
+// gravityNeighbours is the number of nearest cities connected to every city
+const gravityNeighbours = 2
+
+// connect returns the pairs of city indexes joined by a road, the smaller index first:
+// the minimum spanning tree keeps every city reachable and the nearest neighbours add alternative routes
+func connect(cities []api.CityData) [][2]int {
+	distance := func(i, j int) float64 { return api.Distance(cities[i].Pos, cities[j].Pos) }
+	pairs := make(map[[2]int]bool)
+	add := func(i, j int) {
+		if i > j {
+			i, j = j, i
+		}
+		pairs[[2]int{i, j}] = true
+	}
+
+	// Prim's algorithm
+	inTree := make([]bool, len(cities))
+	nearest, nearestDistance := make([]int, len(cities)), make([]float64, len(cities))
+	for i := range nearestDistance {
+		nearest[i], nearestDistance[i] = -1, math.Inf(1)
+	}
+	for next := 0; next >= 0 && len(cities) > 0; {
+		inTree[next] = true
+		if nearest[next] >= 0 {
+			add(next, nearest[next])
+		}
+		for i := range cities {
+			if d := distance(next, i); !inTree[i] && d < nearestDistance[i] {
+				nearest[i], nearestDistance[i] = next, d
+			}
+		}
+		next = -1
+		for i := range cities {
+			if !inTree[i] && (next < 0 || nearestDistance[i] < nearestDistance[next]) {
+				next = i
+			}
+		}
+	}
+
+	for i := range cities {
+		others := make([]int, 0, len(cities)-1)
+		for j := range cities {
+			if j != i {
+				others = append(others, j)
+			}
+		}
+		sort.SliceStable(others, func(a, b int) bool { return distance(i, others[a]) < distance(i, others[b]) })
+		for k := 0; k < gravityNeighbours && k < len(others); k++ {
+			add(i, others[k])
+		}
+	}
+
+	sorted := make([][2]int, 0, len(pairs))
+	for pair := range pairs {
+		sorted = append(sorted, pair)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a][0] != sorted[b][0] {
+			return sorted[a][0] < sorted[b][0]
+		}
+		return sorted[a][1] < sorted[b][1]
+	})
+	return sorted
+}
@@ -0,0 +1,60 @@
+package sampledata
+
+import (
+	api "github.com/bisoncorp/autostrade/gameapi"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestPopulation(t *testing.T) {
+	if n, exist := Population("roma"); !exist || n != 2761477 {
+		t.Fatalf("population of Roma is %d", n)
+	}
+	if names := MostPopulated(3); len(names) != 3 || names[0] != "Roma" || names[1] != "Milano" || names[2] != "Napoli" {
+		t.Fatalf("most populated are %v", names)
+	}
+}
+
+func TestGravityDemand(t *testing.T) {
+	cities := []api.CityData{
+		{Name: "Roma", Pos: api.Position{X: 0, Y: 0}},
+		{Name: "Milano", Pos: api.Position{X: 100, Y: 0}},
+		{Name: "Napoli", Pos: api.Position{X: 200, Y: 0}},
+		{Name: "Atlantide", Pos: api.Position{X: 0, Y: 100}},
+	}
+	cities, demand := GravityDemand(cities, GravityOptions{TripsPerHour: 100})
+	if cities[0].GenerationTime >= cities[1].GenerationTime || cities[3].GenerationTime != 0 {
+		t.Fatalf("unexpected generation times %v, %v, %v", cities[0].GenerationTime, cities[1].GenerationTime, cities[3].GenerationTime)
+	}
+	weights := make(map[api.ODPair]float64)
+	for _, d := range demand {
+		weights[d.ODPair] = d.Weight
+	}
+	if len(weights) != 6 {
+		t.Fatalf("unexpected demand %v", demand)
+	}
+	if weights[api.ODPair{Origin: "Milano", Destination: "Roma"}] <= weights[api.ODPair{Origin: "Milano", Destination: "Napoli"}] {
+		t.Fatal("more populated city at the same distance attracts less trips")
+	}
+	if weights[api.ODPair{Origin: "Roma", Destination: "Milano"}] <= weights[api.ODPair{Origin: "Roma", Destination: "Napoli"}] {
+		t.Fatal("city at half distance attracts less trips")
+	}
+}
+
+func TestGravityScenario(t *testing.T) {
+	data := GravityScenario(20, 600, 600, GravityOptions{}, rand.New(rand.NewSource(1)))
+	if err := data.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Cities) != 20 || len(data.Roads) < 2*19 {
+		t.Fatalf("%d cities and %d roads", len(data.Cities), len(data.Roads))
+	}
+	total := 0.0
+	for _, c := range data.Cities {
+		total += float64(time.Hour) / float64(c.GenerationTime)
+	}
+	if total < 599 || total > 601 {
+		t.Fatalf("%f trips per hour", total)
+	}
+}